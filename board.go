@@ -0,0 +1,180 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/opennikish/tetris/internal/autorepeat"
+	"github.com/opennikish/tetris/internal/game"
+	"github.com/opennikish/tetris/internal/keymap"
+	"github.com/opennikish/tetris/internal/netplay"
+	"github.com/opennikish/tetris/internal/terminal"
+	"github.com/opennikish/tetris/internal/tui"
+)
+
+// board is a game on the screen together with its controls, versus plays two of them side by side.
+type board struct {
+	gameplay    *game.Gameplay
+	renderer    *tui.PlayfieldRenderer
+	keymap      *keymap.Keymap
+	ticker      Ticker
+	repeater    *autorepeat.Repeater
+	gravity     time.Duration
+	currGravity time.Duration
+	fieldCache  [][]game.CellKind
+	ghost       *game.Tetromino // the ghost on the screen
+	sent        int             // garbage lines sent to the opponent
+	over        bool            // topped out in a network game, waiting for the result
+}
+
+func (b *board) cacheField() {
+	field := b.gameplay.Field()
+	b.fieldCache = make([][]game.CellKind, field.Height())
+	for i := range b.fieldCache {
+		b.fieldCache[i] = make([]game.CellKind, field.Width())
+		field.CopyLine(i, b.fieldCache[i])
+	}
+}
+
+// versusKeys split the keyboard: the left player plays WASD and space, the right one arrows and enter.
+var versusKeys = [2]keymap.Config{
+	{Preset: "wasd"},
+	{Preset: "arrows", Bindings: keymap.Bindings{keymap.HardDrop: {"enter"}}},
+}
+
+// versusGap is the space between the boards of versus, meterWidth is the room of the garbage meter
+// right of the board, it shares the gap before the preview when there is one.
+const (
+	versusGap  = 4
+	meterWidth = 2
+)
+
+// SetSecondPlayer gives the second board its gravity ticker and auto-repeat, which makes versus games possible.
+func (a *App) SetSecondPlayer(ticker Ticker, repeater *autorepeat.Repeater) {
+	first := a.boards[0]
+	b := &board{
+		renderer:    tui.NewPlayfieldRenderer(a.term, 0, 0),
+		ticker:      ticker,
+		repeater:    repeater,
+		gravity:     first.gravity,
+		currGravity: first.gravity,
+	}
+	a.boards = append(a.boards[:1], b)
+	a.applySettings()
+}
+
+// SetVersus starts with a versus game against the rival, see SetSecondPlayer.
+func (a *App) SetVersus(rival *game.Gameplay) {
+	a.boards[1].gameplay = rival
+	a.setVersus(true)
+}
+
+// setVersus splits the keyboard between the boards of versus, a single player keeps the keys of the settings.
+func (a *App) setVersus(enabled bool) {
+	a.versus = enabled
+	a.loser = nil
+	a.boards[0].keymap = a.keymap
+	for _, b := range a.boards {
+		b.sent = 0
+		b.over = false
+	}
+	if !enabled || a.remote != nil {
+		return // the opponent of a network game plays on its own keyboard
+	}
+	for i, b := range a.boards {
+		km, err := keymap.New(versusKeys[i])
+		if err != nil {
+			panic(fmt.Sprintf("versus keymap: %s", err))
+		}
+		b.keymap = km
+	}
+}
+
+// playing returns the boards of the current game.
+func (a *App) playing() []*board {
+	if a.versus {
+		return a.boards
+	}
+	return a.boards[:1]
+}
+
+// opponent returns the other board of versus.
+func (a *App) opponent(b *board) *board {
+	if b == a.boards[0] {
+		return a.boards[1]
+	}
+	return a.boards[0]
+}
+
+// boardAction finds the board whose keys include the key, in versus each player has own keys.
+func (a *App) boardAction(k terminal.Key) (*board, keymap.Action, bool) {
+	for _, b := range a.playing() {
+		if action, ok := b.keymap.Action(k); ok {
+			return b, action, true
+		}
+	}
+	return a.boards[0], "", false
+}
+
+// layoutSize returns the size of the boards side by side.
+func (a *App) layoutSize() (lines, cols int) {
+	for i, b := range a.playing() {
+		l, _ := tui.LayoutSize(b.gameplay.Field(), a.preview)
+		if i > 0 {
+			cols += versusGap
+		}
+		lines, cols = max(lines, l), cols+a.boardCols(b)
+	}
+	return lines, cols
+}
+
+// boardCols returns the width of the board along with the preview, and the meter in versus.
+func (a *App) boardCols(b *board) int {
+	_, cols := tui.LayoutSize(b.gameplay.Field(), a.preview)
+	if a.versus && a.preview == 0 {
+		cols += meterWidth
+	}
+	return cols
+}
+
+// drawMeters shows the garbage waiting to rise on each board of versus.
+func (a *App) drawMeters() {
+	if !a.versus {
+		return
+	}
+	for _, b := range a.boards {
+		b.renderer.DrawMeter(b.gameplay.Field(), b.gameplay.PendingGarbage())
+	}
+}
+
+// onAttack sends the garbage to the opponent, it's cancelled by what the opponent sends back.
+func (a *App) onAttack(b *board, lines int) {
+	if !a.versus {
+		return
+	}
+	b.sent += lines
+	a.logger.Debug("attack", "lines", lines)
+	if a.remote != nil {
+		a.send(b, func(c *netplay.Conn) error { return c.Attack(lines) }) // the copy of the opponent's game only counts
+		return
+	}
+	a.opponent(b).gameplay.ReceiveGarbage(lines)
+}
+
+// versusLines are the results of the board in the versus summary.
+func (a *App) versusLines(b *board) []string {
+	result := []string{"", " GAME OVER", " " + string(a.overReason), ""}
+	switch {
+	case a.loser == b:
+		result[1] = " LOSES"
+	case a.loser != nil:
+		result[1], result[2] = " WINS", ""
+	}
+
+	return append(result,
+		fmt.Sprintf(" %-6s %d", "Lines", b.gameplay.Lines()),
+		fmt.Sprintf(" %-6s %d", "Sent", b.sent),
+		fmt.Sprintf(" %-6s %.2f", "PPS", a.pps(b)),
+		fmt.Sprintf(" %-6s %d", "Faults", b.gameplay.Faults()),
+	)
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"github.com/opennikish/tetris/internal/game"
+	"github.com/opennikish/tetris/internal/spectate"
+)
+
+// SetBroadcast shows the game to the watchers of the broadcaster, in versus the first board.
+func (a *App) SetBroadcast(b *spectate.Broadcaster) {
+	a.broadcast = b
+}
+
+// publish sends the state of the game to the watchers, the broadcaster sends only what has changed.
+// The menu keeps the last game on their screens.
+func (a *App) publish() {
+	if a.broadcast == nil || a.menu != menuNone {
+		return
+	}
+
+	g := a.boards[0].gameplay
+	field := g.Field()
+	s := spectate.State{
+		Mode:   a.mode,
+		Field:  make([][]game.CellKind, field.Height()),
+		Piece:  g.CurrentTetromino().Points,
+		Score:  g.Score(),
+		Lines:  g.Lines(),
+		Level:  g.Level(),
+		Time:   a.elapsed,
+		Over:   a.gameOver || a.nameEntry,
+		Reason: string(a.overReason),
+	}
+	for i := range s.Field {
+		s.Field[i] = make([]game.CellKind, field.Width())
+		field.CopyLine(i, s.Field[i])
+	}
+	for _, t := range g.Preview(a.preview) {
+		s.Next = append(s.Next, t.Points)
+	}
+	a.broadcast.Publish(s)
+}
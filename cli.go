@@ -0,0 +1,907 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"math/rand/v2"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"runtime/debug"
+	"slices"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/opennikish/tetris/internal/autorepeat"
+	"github.com/opennikish/tetris/internal/bot"
+	"github.com/opennikish/tetris/internal/game"
+	"github.com/opennikish/tetris/internal/keymap"
+	"github.com/opennikish/tetris/internal/netplay"
+	"github.com/opennikish/tetris/internal/replay"
+	"github.com/opennikish/tetris/internal/scores"
+	"github.com/opennikish/tetris/internal/settings"
+	"github.com/opennikish/tetris/internal/spectate"
+	"github.com/opennikish/tetris/internal/terminal"
+	"github.com/opennikish/tetris/internal/tui"
+)
+
+// version is set on release builds with -ldflags "-X main.version=v1.2.3".
+var version = "dev"
+
+// Exit codes, usage errors follow the flag package.
+const (
+	exitOK    = 0
+	exitError = 1
+	exitUsage = 2
+)
+
+const usage = `Usage: tetris [command] [flags]
+
+Commands:
+  play     play a game, the default command, starts with the menu unless --mode is given
+  replay   play back a game recorded with "play --record"
+  host     wait for an opponent to join a versus game over the network
+  join     join a versus game hosted by "tetris host"
+  serve    host a game for every telnet client
+  watch    watch a game broadcast with "play --broadcast"
+  scores   list the high scores
+  version  print the version
+
+Run "tetris <command> -h" to see the command flags.
+`
+
+// Game modes.
+const (
+	modeMarathon = "marathon"
+	modeSprint   = "sprint"
+	modeSandbox  = "sandbox"
+	modeVersus   = "versus"
+)
+
+var modes = []string{modeMarathon, modeSprint, modeSandbox, modeVersus}
+
+// sprintLines is how many lines a sprint takes.
+const sprintLines = 40
+
+type playConfig struct {
+	seed      uint64
+	mode      string
+	level     int
+	width     int
+	height    int
+	theme     string
+	log       logConfig
+	record    string
+	scores    string
+	menu      bool // no mode is given, the player picks it in the menu
+	bot       bool
+	broadcast string
+}
+
+// scoreOrder ranks the games of the modes with a goal by time.
+func scoreOrder(mode string) scores.Order {
+	if mode == modeSprint {
+		return scores.ByTime
+	}
+	return scores.ByScore
+}
+
+func (c playConfig) options() game.Options {
+	opts := game.Options{Width: c.width, Height: c.height, Level: c.level}
+	if c.mode == modeSprint {
+		opts.LineGoal = sprintLines
+	}
+	return opts
+}
+
+type netConfig struct {
+	addr   string
+	seed   uint64
+	level  int
+	width  int
+	height int
+	theme  string
+	log    logConfig
+}
+
+func (c netConfig) options() game.Options {
+	return playConfig{mode: modeVersus, level: c.level, width: c.width, height: c.height}.options()
+}
+
+type serveConfig struct {
+	telnet   string
+	sessions int
+	theme    string
+	scores   string
+	log      logConfig
+}
+
+type watchConfig struct {
+	addr  string
+	theme string
+	log   logConfig
+}
+
+type replayConfig struct {
+	file  string
+	speed float64
+	theme string
+	log   logConfig
+}
+
+// run executes the command line and returns the exit code.
+func run(args []string, stdout, stderr io.Writer) int {
+	cmd := "play"
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		cmd, args = args[0], args[1:]
+	}
+
+	var err error
+	switch cmd {
+	case "play":
+		var cfg playConfig
+		if cfg, err = parsePlayFlags(args, stderr); err == nil {
+			err = runPlay(cfg)
+		}
+	case "replay":
+		var cfg replayConfig
+		if cfg, err = parseReplayFlags(args, stderr); err == nil {
+			err = runReplay(cfg)
+		}
+	case "host":
+		var cfg netConfig
+		if cfg, err = parseHostFlags(args, stderr); err == nil {
+			err = runHost(cfg, stdout)
+		}
+	case "join":
+		var cfg netConfig
+		if cfg, err = parseJoinFlags(args, stderr); err == nil {
+			err = runJoin(cfg)
+		}
+	case "serve":
+		var cfg serveConfig
+		if cfg, err = parseServeFlags(args, stderr); err == nil {
+			err = runServe(cfg, stdout)
+		}
+	case "watch":
+		var cfg watchConfig
+		if cfg, err = parseWatchFlags(args, stderr); err == nil {
+			err = runWatch(cfg)
+		}
+	case "scores":
+		var cfg scoresConfig
+		if cfg, err = parseScoresFlags(args, stderr); err == nil {
+			err = runScores(cfg, stdout)
+		}
+	case "version":
+		if err = parseNoFlags("version", args, stderr); err == nil {
+			fmt.Fprintf(stdout, "tetris %s\n", buildVersion())
+		}
+	case "help":
+		fmt.Fprint(stdout, usage)
+	default:
+		fmt.Fprintf(stderr, "tetris: unknown command %q\n\n%s", cmd, usage)
+		return exitUsage
+	}
+
+	var usageErr usageError
+	switch {
+	case err == nil:
+		return exitOK
+	case errors.Is(err, flag.ErrHelp):
+		return exitOK
+	case errors.As(err, &usageErr):
+		if usageErr.reported {
+			return exitUsage
+		}
+		fmt.Fprintf(stderr, "tetris %s: %s\nRun \"tetris %s -h\" for usage.\n", cmd, err, cmd)
+		return exitUsage
+	default:
+		fmt.Fprintf(stderr, "%s\n", err)
+		return exitError
+	}
+}
+
+// usageError is a problem with the command line rather than with running the command.
+type usageError struct {
+	err      error
+	reported bool // the flag package has already printed it with the usage
+}
+
+func (e usageError) Error() string { return e.err.Error() }
+
+func (e usageError) Unwrap() error { return e.err }
+
+func newFlagSet(name, synopsis string, stderr io.Writer) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprintf(stderr, "Usage: tetris %s\n", synopsis)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// parseFlags wraps the flag package errors, which it has already printed along with the usage.
+func parseFlags(fs *flag.FlagSet, args []string) error {
+	err := fs.Parse(args)
+	if err != nil && !errors.Is(err, flag.ErrHelp) {
+		return usageError{err: err, reported: true}
+	}
+	return err
+}
+
+func parseNoFlags(name string, args []string, stderr io.Writer) error {
+	fs := newFlagSet(name, name, stderr)
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		return usageError{err: fmt.Errorf("unexpected arguments: %s", strings.Join(fs.Args(), " "))}
+	}
+	return nil
+}
+
+func parsePlayFlags(args []string, stderr io.Writer) (playConfig, error) {
+	var cfg playConfig
+	fs := newFlagSet("play", "[play] [flags]", stderr)
+	fs.Uint64Var(&cfg.seed, "seed", 0, "seed of the tetromino sequence, random if 0")
+	fs.StringVar(&cfg.mode, "mode", modeMarathon, fmt.Sprintf("game mode: %s, starts the game without the menu", strings.Join(modes, ", ")))
+	fs.IntVar(&cfg.level, "level", 1, fmt.Sprintf("starting level, 1..%d", game.MaxLevel))
+	fs.IntVar(&cfg.width, "width", game.DefaultWidth, fmt.Sprintf("playfield width, %d..%d", game.MinSize, game.MaxSize))
+	fs.IntVar(&cfg.height, "height", game.DefaultHeight, fmt.Sprintf("playfield height, %d..%d", game.MinSize, game.MaxSize))
+	fs.StringVar(&cfg.theme, "theme", "", fmt.Sprintf("cell theme: %s, overrides the settings", strings.Join(tui.ThemeNames(), ", ")))
+	addLogFlags(fs, &cfg.log)
+	fs.StringVar(&cfg.record, "record", "", "record the game to the file for replay")
+	fs.BoolVar(&cfg.bot, "bot", false, "let the built-in AI play, e.g. as a demo")
+	fs.StringVar(&cfg.scores, "scores", "", "high-score file, e.g. on a shared dir for a team leaderboard (default $XDG_DATA_HOME/tetris/scores.json)")
+	fs.StringVar(&cfg.broadcast, "broadcast", "", `show the game to "tetris watch" on the address, host:port or unix:/path`)
+
+	if err := parseFlags(fs, args); err != nil {
+		return playConfig{}, err
+	}
+	if fs.NArg() > 0 {
+		return playConfig{}, usageError{err: fmt.Errorf("unexpected arguments: %s", strings.Join(fs.Args(), " "))}
+	}
+	cfg.menu = cfg.record == ""
+	fs.Visit(func(f *flag.Flag) {
+		if f.Name == "mode" {
+			cfg.menu = false
+		}
+	})
+	if !slices.Contains(modes, cfg.mode) {
+		return playConfig{}, usageError{err: fmt.Errorf("unknown mode %q, available: %s", cfg.mode, strings.Join(modes, ", "))}
+	}
+	if cfg.record != "" && (cfg.mode == modeSandbox || cfg.mode == modeVersus) {
+		return playConfig{}, usageError{err: fmt.Errorf("%s games can't be recorded", cfg.mode)}
+	}
+	if err := checkTheme(cfg.theme); err != nil {
+		return playConfig{}, err
+	}
+	if err := cfg.log.check(); err != nil {
+		return playConfig{}, err
+	}
+	if err := cfg.options().Validate(); err != nil {
+		return playConfig{}, usageError{err: err}
+	}
+
+	return cfg, nil
+}
+
+func parseReplayFlags(args []string, stderr io.Writer) (replayConfig, error) {
+	var cfg replayConfig
+	fs := newFlagSet("replay", "replay [flags] <file>", stderr)
+	fs.Float64Var(&cfg.speed, "speed", 1, "playback speed, e.g. 2 plays twice as fast")
+	fs.StringVar(&cfg.theme, "theme", "", fmt.Sprintf("cell theme: %s, overrides the settings", strings.Join(tui.ThemeNames(), ", ")))
+	addLogFlags(fs, &cfg.log)
+
+	if err := parseFlags(fs, args); err != nil {
+		return replayConfig{}, err
+	}
+	if fs.NArg() != 1 {
+		return replayConfig{}, usageError{err: errors.New("expected exactly one replay file")}
+	}
+	cfg.file = fs.Arg(0)
+	if cfg.speed <= 0 {
+		return replayConfig{}, usageError{err: fmt.Errorf("speed must be positive, got %g", cfg.speed)}
+	}
+	if err := checkTheme(cfg.theme); err != nil {
+		return replayConfig{}, err
+	}
+	if err := cfg.log.check(); err != nil {
+		return replayConfig{}, err
+	}
+
+	return cfg, nil
+}
+
+func parseHostFlags(args []string, stderr io.Writer) (netConfig, error) {
+	var cfg netConfig
+	fs := newFlagSet("host", "host [flags] <addr>", stderr)
+	fs.Uint64Var(&cfg.seed, "seed", 0, "seed of the tetromino sequence, random if 0")
+	fs.IntVar(&cfg.level, "level", 1, fmt.Sprintf("starting level, 1..%d", game.MaxLevel))
+	fs.IntVar(&cfg.width, "width", game.DefaultWidth, fmt.Sprintf("playfield width, %d..%d", game.MinSize, game.MaxSize))
+	fs.IntVar(&cfg.height, "height", game.DefaultHeight, fmt.Sprintf("playfield height, %d..%d", game.MinSize, game.MaxSize))
+	fs.StringVar(&cfg.theme, "theme", "", fmt.Sprintf("cell theme: %s, overrides the settings", strings.Join(tui.ThemeNames(), ", ")))
+	addLogFlags(fs, &cfg.log)
+
+	if err := parseFlags(fs, args); err != nil {
+		return netConfig{}, err
+	}
+	if fs.NArg() != 1 {
+		return netConfig{}, usageError{err: errors.New("expected exactly one address to listen on, e.g. :7777")}
+	}
+	cfg.addr = fs.Arg(0)
+	if err := checkTheme(cfg.theme); err != nil {
+		return netConfig{}, err
+	}
+	if err := cfg.log.check(); err != nil {
+		return netConfig{}, err
+	}
+	if err := cfg.options().Validate(); err != nil {
+		return netConfig{}, usageError{err: err}
+	}
+
+	return cfg, nil
+}
+
+// parseJoinFlags takes no game flags, the host picks the game.
+func parseJoinFlags(args []string, stderr io.Writer) (netConfig, error) {
+	var cfg netConfig
+	fs := newFlagSet("join", "join [flags] <addr>", stderr)
+	fs.StringVar(&cfg.theme, "theme", "", fmt.Sprintf("cell theme: %s, overrides the settings", strings.Join(tui.ThemeNames(), ", ")))
+	addLogFlags(fs, &cfg.log)
+
+	if err := parseFlags(fs, args); err != nil {
+		return netConfig{}, err
+	}
+	if fs.NArg() != 1 {
+		return netConfig{}, usageError{err: errors.New("expected exactly one host address, e.g. example.com:7777")}
+	}
+	cfg.addr = fs.Arg(0)
+	if err := checkTheme(cfg.theme); err != nil {
+		return netConfig{}, err
+	}
+	if err := cfg.log.check(); err != nil {
+		return netConfig{}, err
+	}
+
+	return cfg, nil
+}
+
+func parseServeFlags(args []string, stderr io.Writer) (serveConfig, error) {
+	var cfg serveConfig
+	fs := newFlagSet("serve", "serve --telnet <addr> [flags]", stderr)
+	fs.StringVar(&cfg.telnet, "telnet", "", "address to serve telnet clients on, e.g. :2323")
+	fs.IntVar(&cfg.sessions, "max-sessions", 16, "most games played at once, more clients are turned away")
+	fs.StringVar(&cfg.theme, "theme", "", fmt.Sprintf("cell theme: %s", strings.Join(tui.ThemeNames(), ", ")))
+	fs.StringVar(&cfg.scores, "scores", "", "high-score file shared by the sessions (default $XDG_DATA_HOME/tetris/scores.json)")
+	addLogFlags(fs, &cfg.log)
+
+	if err := parseFlags(fs, args); err != nil {
+		return serveConfig{}, err
+	}
+	if fs.NArg() > 0 {
+		return serveConfig{}, usageError{err: fmt.Errorf("unexpected arguments: %s", strings.Join(fs.Args(), " "))}
+	}
+	if cfg.telnet == "" {
+		return serveConfig{}, usageError{err: errors.New("the --telnet address is required")}
+	}
+	if cfg.sessions < 1 {
+		return serveConfig{}, usageError{err: fmt.Errorf("max sessions must be positive, got %d", cfg.sessions)}
+	}
+	if err := checkTheme(cfg.theme); err != nil {
+		return serveConfig{}, err
+	}
+	if err := cfg.log.check(); err != nil {
+		return serveConfig{}, err
+	}
+
+	return cfg, nil
+}
+
+func parseWatchFlags(args []string, stderr io.Writer) (watchConfig, error) {
+	var cfg watchConfig
+	fs := newFlagSet("watch", "watch [flags] <addr>", stderr)
+	fs.StringVar(&cfg.theme, "theme", "", fmt.Sprintf("cell theme: %s, overrides the settings", strings.Join(tui.ThemeNames(), ", ")))
+	addLogFlags(fs, &cfg.log)
+
+	if err := parseFlags(fs, args); err != nil {
+		return watchConfig{}, err
+	}
+	if fs.NArg() != 1 {
+		return watchConfig{}, usageError{err: errors.New("expected exactly one broadcast address, host:port or unix:/path")}
+	}
+	cfg.addr = fs.Arg(0)
+	if err := checkTheme(cfg.theme); err != nil {
+		return watchConfig{}, err
+	}
+	if err := cfg.log.check(); err != nil {
+		return watchConfig{}, err
+	}
+
+	return cfg, nil
+}
+
+func checkTheme(name string) error {
+	if _, ok := tui.Themes[name]; name != "" && !ok {
+		return usageError{err: fmt.Errorf("unknown theme %q, available: %s", name, strings.Join(tui.ThemeNames(), ", "))}
+	}
+	return nil
+}
+
+func runPlay(cfg playConfig) error {
+	logger, closeLog, err := openLog(cfg.log)
+	if err != nil {
+		return err
+	}
+	defer closeLog()
+
+	s, save, err := loadSettings(logger)
+	if err != nil {
+		return err
+	}
+	if cfg.theme != "" {
+		s.Theme = cfg.theme
+	}
+
+	var seed uint64
+	newGame := gameFactory(cfg, logger, &seed)
+	games := []*game.Gameplay{menuGame(cfg)}
+	if !cfg.menu {
+		games = newGame(cfg.mode)
+	}
+	app := newApp(games[0], s, save)
+	app.SetLogger(logger)
+	if cfg.bot {
+		app.SetBot(bot.New(bot.DefaultWeights()))
+	}
+	app.SetSandbox(cfg.mode == modeSandbox)
+	if len(games) > 1 {
+		app.SetVersus(games[1])
+	}
+	switch {
+	case cfg.menu:
+		app.SetMenu(newGame)
+	case cfg.record == "":
+		app.SetNewGame(newGame) // a recording keeps a single game
+	}
+
+	path, err := scoresPath(cfg.scores)
+	if err != nil {
+		return err
+	}
+	app.SetScores(scores.NewStore(path, scores.DefaultLimit), cfg.mode, scoreOrder(cfg.mode), playerName(os.Getenv))
+
+	if cfg.broadcast != "" {
+		b, err := spectate.Listen(cfg.broadcast)
+		if err != nil {
+			return fmt.Errorf("broadcast: %w", err)
+		}
+		defer b.Close()
+		b.SetLogger(logger)
+		app.SetBroadcast(b)
+	}
+
+	if cfg.record != "" {
+		f, err := os.Create(cfg.record)
+		if err != nil {
+			return fmt.Errorf("create replay file: %w", err)
+		}
+		defer f.Close()
+
+		h := replay.Header{Seed: seed, Mode: cfg.mode, Level: cfg.level, Width: cfg.width, Height: cfg.height}
+		rec, err := replay.NewRecorder(f, h, time.Now)
+		if err != nil {
+			return err
+		}
+		app.SetRecorder(rec)
+	}
+
+	return app.Start(context.Background())
+}
+
+// gameFactory starts the games of the config, every game of the menu gets a new seed unless it's given,
+// versus players share it. The seed of the last game is kept in the seed.
+func gameFactory(cfg playConfig, logger *slog.Logger, seed *uint64) func(mode string) []*game.Gameplay {
+	*seed = cfg.seed
+	return func(mode string) []*game.Gameplay {
+		if cfg.seed == 0 {
+			*seed = rand.Uint64()
+		}
+		logger.Info("new game", "mode", mode, "seed", *seed)
+
+		c := cfg
+		c.mode = mode
+		games := []*game.Gameplay{seededGame(*seed, c.options())}
+		if mode == modeVersus {
+			games = append(games, seededGame(*seed, c.options()))
+		}
+		return games
+	}
+}
+
+// menuGame sizes the board of the menu until the player picks a game, it's never played.
+func menuGame(cfg playConfig) *game.Gameplay {
+	return game.NewGameplay(game.SeededRand(cfg.seed), cfg.options())
+}
+
+// seededGame starts a game whose tetrominoes and garbage holes come from the seed.
+func seededGame(seed uint64, opts game.Options) *game.Gameplay {
+	g := game.NewGameplay(game.SeededRand(seed), opts)
+	g.SetGarbageRand(game.GarbageRand(seed))
+	return g
+}
+
+type scoresConfig struct {
+	file string
+	mode string
+}
+
+func parseScoresFlags(args []string, stderr io.Writer) (scoresConfig, error) {
+	var cfg scoresConfig
+	fs := newFlagSet("scores", "scores [flags]", stderr)
+	fs.StringVar(&cfg.file, "file", "", "high-score file (default $XDG_DATA_HOME/tetris/scores.json)")
+	fs.StringVar(&cfg.mode, "mode", "", "list only the mode")
+
+	if err := parseFlags(fs, args); err != nil {
+		return scoresConfig{}, err
+	}
+	if fs.NArg() > 0 {
+		return scoresConfig{}, usageError{err: fmt.Errorf("unexpected arguments: %s", strings.Join(fs.Args(), " "))}
+	}
+	if cfg.mode != "" && !slices.Contains(modes, cfg.mode) {
+		return scoresConfig{}, usageError{err: fmt.Errorf("unknown mode %q, available: %s", cfg.mode, strings.Join(modes, ", "))}
+	}
+
+	return cfg, nil
+}
+
+// runScores prints the high-score tables, the modes in the order of the mode flag help.
+func runScores(cfg scoresConfig, stdout io.Writer) error {
+	path, err := scoresPath(cfg.file)
+	if err != nil {
+		return err
+	}
+	tables, err := scores.NewStore(path, scores.DefaultLimit).Load()
+	if err != nil {
+		return err
+	}
+
+	printed := false
+	for _, mode := range modes {
+		table := tables[mode]
+		if len(table) == 0 || cfg.mode != "" && cfg.mode != mode {
+			continue
+		}
+		if printed {
+			fmt.Fprintln(stdout)
+		}
+		printed = true
+
+		fmt.Fprintln(stdout, mode)
+		w := tabwriter.NewWriter(stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "#\tName\tScore\tLines\tLevel\tTime\tDate")
+		for i, e := range table {
+			fmt.Fprintf(w, "%d\t%s\t%d\t%d\t%d\t%s\t%s\n", i+1, e.Name, e.Score, e.Lines, e.Level, formatDuration(e.Duration), e.Date.Format(time.DateOnly))
+		}
+		w.Flush()
+	}
+
+	if !printed {
+		fmt.Fprintln(stdout, "No high scores yet.")
+	}
+	return nil
+}
+
+// formatDuration prints the game time as m:ss.cc, the usual way of sprint records.
+func formatDuration(d time.Duration) string {
+	d = d.Round(10 * time.Millisecond)
+	return fmt.Sprintf("%d:%02d.%02d", int(d.Minutes()), int(d.Seconds())%60, int(d.Milliseconds()/10)%100)
+}
+
+func scoresPath(file string) (string, error) {
+	if file != "" {
+		return file, nil
+	}
+	dir, err := scores.Dir(os.Getenv)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, scores.FileName), nil
+}
+
+// playerName is the name offered for the high-score table, the login name if known.
+func playerName(getenv func(key string) string) string {
+	for _, key := range []string{"USER", "LOGNAME", "USERNAME"} {
+		if name := getenv(key); name != "" {
+			return name
+		}
+	}
+	return ""
+}
+
+func runReplay(cfg replayConfig) error {
+	logger, closeLog, err := openLog(cfg.log)
+	if err != nil {
+		return err
+	}
+	defer closeLog()
+
+	s, _, err := loadSettings(logger)
+	if err != nil {
+		return err
+	}
+	if cfg.theme != "" {
+		s.Theme = cfg.theme
+	}
+
+	f, err := os.Open(cfg.file)
+	if err != nil {
+		return fmt.Errorf("open replay: %w", err)
+	}
+	h, steps, err := replay.Load(f)
+	f.Close()
+	if err != nil {
+		return fmt.Errorf("load replay %s: %w", cfg.file, err)
+	}
+
+	opts := playConfig{mode: h.Mode, level: h.Level, width: h.Width, height: h.Height}.options()
+	if err := opts.Validate(); err != nil {
+		return fmt.Errorf("load replay %s: %w", cfg.file, err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	app := newApp(seededGame(h.Seed, opts), s, nil)
+	app.SetLogger(logger)
+	app.SetReplay(replay.Play(ctx, steps, cfg.speed))
+
+	return app.Start(ctx)
+}
+
+func runHost(cfg netConfig, stdout io.Writer) error {
+	ln, err := netplay.Listen(cfg.addr)
+	if err != nil {
+		return err
+	}
+	defer ln.Close()
+
+	seed := cfg.seed
+	if seed == 0 {
+		seed = rand.Uint64()
+	}
+	g := netplay.Game{Seed: seed, Level: cfg.level, Width: cfg.width, Height: cfg.height}
+
+	fmt.Fprintf(stdout, "Waiting for the opponent on %s, Ctrl-C to cancel...\n", ln.Addr())
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	conn, err := ln.Accept(ctx, g)
+	stop()
+	if errors.Is(err, context.Canceled) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return runNetGame(cfg, conn)
+}
+
+func runJoin(cfg netConfig) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	conn, err := netplay.Dial(ctx, cfg.addr)
+	stop()
+	if errors.Is(err, context.Canceled) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	g := conn.Game()
+	cfg.level, cfg.width, cfg.height = g.Level, g.Width, g.Height
+	if err := cfg.options().Validate(); err != nil {
+		conn.Close()
+		return fmt.Errorf("join %s: %w", cfg.addr, err)
+	}
+	return runNetGame(cfg, conn)
+}
+
+// runNetGame plays the game agreed on the connection, both sides start with the same seed.
+func runNetGame(cfg netConfig, conn *netplay.Conn) error {
+	defer conn.Close()
+
+	logger, closeLog, err := openLog(cfg.log)
+	if err != nil {
+		return err
+	}
+	defer closeLog()
+
+	s, save, err := loadSettings(logger)
+	if err != nil {
+		return err
+	}
+	if cfg.theme != "" {
+		s.Theme = cfg.theme
+	}
+
+	g := conn.Game()
+	logger.Info("network game", "addr", cfg.addr, "seed", g.Seed)
+	app := newApp(seededGame(g.Seed, cfg.options()), s, save)
+	app.SetLogger(logger)
+	app.SetRemote(conn, seededGame(g.Seed, cfg.options()))
+
+	return app.Start(context.Background())
+}
+
+func runWatch(cfg watchConfig) error {
+	logger, closeLog, err := openLog(cfg.log)
+	if err != nil {
+		return err
+	}
+	defer closeLog()
+
+	s, _, err := loadSettings(logger)
+	if err != nil {
+		return err
+	}
+	if cfg.theme != "" {
+		s.Theme = cfg.theme
+	}
+	km, err := keymap.New(s.Keys)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	conn, err := spectate.Dial(ctx, cfg.addr)
+	if err != nil {
+		return fmt.Errorf("watch %s: %w", cfg.addr, err)
+	}
+	defer conn.Close()
+
+	term := terminal.NewTerminal(os.Stdin, os.Stdout, terminal.NewTermios(os.Stdin))
+	term.SetSyncOutput(terminal.SupportsSyncOutput(os.Getenv))
+	renderer := tui.NewPlayfieldRenderer(term, 0, 0)
+	renderer.SetTheme(tui.Themes[s.Theme])
+	mode := s.ColorMode
+	if mode == tui.ColorAuto {
+		mode = tui.DetectColorMode(os.Getenv)
+	}
+	renderer.SetColorMode(mode)
+
+	w := NewWatcher(term, renderer, km)
+	w.SetLogger(logger)
+	if err := w.Start(ctx, conn.Receive(ctx)); err != nil {
+		return err
+	}
+	cancel()
+	if err := conn.Err(); err != nil {
+		logger.Error("broadcast", "err", err)
+	}
+	return nil
+}
+
+func newApp(gameplay *game.Gameplay, s settings.Settings, save func(settings.Settings) error) *App {
+	term := terminal.NewTerminal(os.Stdin, os.Stdout, terminal.NewTermios(os.Stdin))
+	term.SetSyncOutput(terminal.SupportsSyncOutput(os.Getenv))
+	return newTermApp(term, gameplay, s, save)
+}
+
+// newTermApp builds the app on the terminal, the local one or a remote session.
+func newTermApp(term *terminal.Terminal, gameplay *game.Gameplay, s settings.Settings, save func(settings.Settings) error) *App {
+	app := NewApp(
+		gameplay,
+		term,
+		tui.NewPlayfieldRenderer(term, 0, 0),
+		NewRealTicker(gravityFor(time.Duration(s.Gravity), gameplay.Level())),
+		keymap.Default(),
+		NewRealTicker(frameInterval),
+		autorepeat.New(s.Repeat(), time.Now),
+	)
+	app.SetSettings(s, save)
+	app.SetSecondPlayer(
+		NewRealTicker(gravityFor(time.Duration(s.Gravity), gameplay.Level())),
+		autorepeat.New(s.Repeat(), time.Now),
+	)
+	return app
+}
+
+// loadSettings reads the settings file, defaults are used if there is none yet.
+// Key bindings of the older keys.json are picked up until the settings are saved for the first time.
+func loadSettings(logger *slog.Logger) (settings.Settings, func(settings.Settings) error, error) {
+	dir, err := settings.Dir(os.Getenv)
+	if err != nil {
+		logger.Warn("settings won't be saved", "err", err)
+		return settings.Default(), nil, nil
+	}
+
+	path := filepath.Join(dir, settings.FileName)
+	save := func(s settings.Settings) error {
+		return settings.Save(path, s)
+	}
+
+	s, err := settings.Load(path)
+	if err == nil {
+		return s, save, nil
+	}
+	if !errors.Is(err, fs.ErrNotExist) {
+		return settings.Settings{}, nil, err
+	}
+
+	s = settings.Default()
+	keys, err := keymap.LoadConfig(filepath.Join(dir, "keys.json"))
+	if errors.Is(err, fs.ErrNotExist) {
+		return s, save, nil
+	}
+	if err != nil {
+		return settings.Settings{}, nil, err
+	}
+	if _, err := keymap.New(keys); err != nil {
+		return settings.Settings{}, nil, err
+	}
+	s.Keys = keys
+
+	return s, save, nil
+}
+
+// logConfig tells where and how to log, nothing is logged without the file.
+type logConfig struct {
+	file   string
+	level  slog.Level
+	format string
+}
+
+var logFormats = []string{"text", "json"}
+
+func addLogFlags(fs *flag.FlagSet, cfg *logConfig) {
+	fs.StringVar(&cfg.file, "log", "", "write the log to the file")
+	fs.TextVar(&cfg.level, "log-level", slog.LevelInfo, "log level: debug, info, warn or error, debug logs every tick and key")
+	fs.StringVar(&cfg.format, "log-format", "text", fmt.Sprintf("log format: %s", strings.Join(logFormats, ", ")))
+}
+
+func (c logConfig) check() error {
+	if !slices.Contains(logFormats, c.format) {
+		return usageError{err: fmt.Errorf("unknown log format %q, available: %s", c.format, strings.Join(logFormats, ", "))}
+	}
+	return nil
+}
+
+// openLog creates the logger shared by the app, the game and the terminal.
+// The log can't go to stderr, it would mess up the screen.
+func openLog(cfg logConfig) (*slog.Logger, func(), error) {
+	if cfg.file == "" {
+		return slog.New(slog.NewTextHandler(io.Discard, nil)), func() {}, nil
+	}
+
+	f, err := os.OpenFile(cfg.file, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, nil, fmt.Errorf("open log file: %w", err)
+	}
+
+	opts := &slog.HandlerOptions{Level: cfg.level}
+	var h slog.Handler = slog.NewTextHandler(f, opts)
+	if cfg.format == "json" {
+		h = slog.NewJSONHandler(f, opts)
+	}
+	return slog.New(h), func() { f.Close() }, nil
+}
+
+// buildVersion prefers the version set by the linker, then the module version of "go install".
+func buildVersion() string {
+	if version != "dev" {
+		return version
+	}
+	if info, ok := debug.ReadBuildInfo(); ok && info.Main.Version != "" && info.Main.Version != "(devel)" {
+		return info.Main.Version
+	}
+	return version
+}
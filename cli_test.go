@@ -0,0 +1,156 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/opennikish/tetris/internal/game"
+	"github.com/opennikish/tetris/internal/scores"
+)
+
+func TestParsePlayFlags(t *testing.T) {
+	var stderr bytes.Buffer
+	cfg, err := parsePlayFlags([]string{"--seed", "7", "--mode", "sprint", "--level", "3", "--width", "8", "--theme", "ascii"}, &stderr)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	eq(t, uint64(7), cfg.seed)
+	eq(t, "ascii", cfg.theme)
+	eq(t, game.Options{Width: 8, Height: 20, Level: 3, LineGoal: sprintLines}, cfg.options())
+}
+
+func TestPlayShowsMenuWithoutMode(t *testing.T) {
+	var stderr bytes.Buffer
+	cases := []struct {
+		args []string
+		menu bool
+	}{
+		{nil, true},
+		{[]string{"--seed", "7"}, true},
+		{[]string{"--mode", "marathon"}, false},
+		{[]string{"--record", "game.jsonl"}, false},
+	}
+
+	for _, c := range cases {
+		cfg, err := parsePlayFlags(c.args, &stderr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		eq(t, c.menu, cfg.menu)
+	}
+}
+
+func TestRunReportsUsageErrors(t *testing.T) {
+	cases := []struct {
+		args   []string
+		stderr string
+	}{
+		{[]string{"--speed", "2"}, "flag provided but not defined: -speed"},
+		{[]string{"--mode", "ultra"}, `tetris play: unknown mode "ultra", available: marathon, sprint, sandbox`},
+		{[]string{"play", "--width", "2"}, "tetris play: width must be in range 4..40, got 2"},
+		{[]string{"play", "--theme", "neon"}, `tetris play: unknown theme "neon"`},
+		{[]string{"play", "--log-format", "xml"}, `tetris play: unknown log format "xml", available: text, json`},
+		{[]string{"play", "--log-level", "loud"}, `invalid value "loud" for flag -log-level`},
+		{[]string{"play", "--mode", "sandbox", "--record", "game.jsonl"}, "tetris play: sandbox games can't be recorded"},
+		{[]string{"play", "--mode", "versus", "--record", "game.jsonl"}, "tetris play: versus games can't be recorded"},
+		{[]string{"replay"}, "tetris replay: expected exactly one replay file"},
+		{[]string{"replay", "--speed", "0", "game.jsonl"}, "tetris replay: speed must be positive, got 0"},
+		{[]string{"host"}, "tetris host: expected exactly one address to listen on, e.g. :7777"},
+		{[]string{"host", "--height", "99", ":7777"}, "tetris host: height must be in range 4..40, got 99"},
+		{[]string{"join", "a:1", "b:2"}, "tetris join: expected exactly one host address, e.g. example.com:7777"},
+		{[]string{"join", "--seed", "1", "a:1"}, "flag provided but not defined: -seed"},
+		{[]string{"serve"}, "tetris serve: the --telnet address is required"},
+		{[]string{"serve", "--telnet", ":2323", "--max-sessions", "0"}, "tetris serve: max sessions must be positive, got 0"},
+		{[]string{"version", "extra"}, "tetris version: unexpected arguments: extra"},
+		{[]string{"watch"}, "tetris watch: expected exactly one broadcast address, host:port or unix:/path"},
+		{[]string{"watch", "--theme", "neon", ":7778"}, `tetris watch: unknown theme "neon"`},
+		{[]string{"fly"}, `tetris: unknown command "fly"`},
+	}
+
+	for _, c := range cases {
+		var stdout, stderr bytes.Buffer
+		code := run(c.args, &stdout, &stderr)
+
+		eq(t, exitUsage, code)
+		if !strings.Contains(stderr.String(), c.stderr) {
+			t.Fatalf("%v: expected stderr to contain %q, got:\n%s", c.args, c.stderr, stderr.String())
+		}
+	}
+}
+
+func TestRunHelpAndVersion(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	eq(t, exitOK, run([]string{"play", "-h"}, &stdout, &stderr))
+	if !strings.Contains(stderr.String(), "-seed") {
+		t.Fatalf("expected play flags in usage, got:\n%s", stderr.String())
+	}
+
+	stdout.Reset()
+	eq(t, exitOK, run([]string{"version"}, &stdout, &stderr))
+	eq(t, "tetris dev\n", stdout.String())
+}
+
+func TestLogFiltersLevelAndWritesJSON(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tetris.log")
+	var stderr bytes.Buffer
+	cfg, err := parsePlayFlags([]string{"--log", path, "--log-level", "warn", "--log-format", "json"}, &stderr)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	logger, closeLog, err := openLog(cfg.log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger.Debug("tick", "n", 1)
+	logger.Warn("window size", "err", "not a terminal")
+	closeLog()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var record map[string]any
+	if err := json.Unmarshal(data, &record); err != nil {
+		t.Fatalf("expected a single JSON record, got:\n%s", data)
+	}
+	eq(t, "WARN", record["level"])
+	eq(t, "window size", record["msg"])
+	eq(t, "not a terminal", record["err"])
+}
+
+func TestGravityForLevel(t *testing.T) {
+	eq(t, defaultGravity, gravityFor(defaultGravity, 1))
+	eq(t, 400*time.Millisecond, gravityFor(defaultGravity, 2))
+	eq(t, frameInterval, gravityFor(defaultGravity, game.MaxLevel))
+}
+
+func TestRunScoresListsTables(t *testing.T) {
+	path := filepath.Join(t.TempDir(), scores.FileName)
+	store := scores.NewStore(path, scores.DefaultLimit)
+	date := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	store.Add(modeMarathon, scores.ByScore, scores.Entry{Name: "alice", Score: 1200, Lines: 12, Level: 2, Duration: 3*time.Minute + 2*time.Second, Date: date})
+	store.Add(modeSprint, scores.ByTime, scores.Entry{Name: "bob", Score: 4000, Lines: 40, Level: 5, Duration: 75*time.Second + 430*time.Millisecond, Date: date})
+
+	var stdout, stderr bytes.Buffer
+	eq(t, exitOK, run([]string{"scores", "--file", path}, &stdout, &stderr))
+	expected := `marathon
+#  Name   Score  Lines  Level  Time     Date
+1  alice  1200   12     2      3:02.00  2026-10-18
+
+sprint
+#  Name  Score  Lines  Level  Time     Date
+1  bob   4000   40     5      1:15.43  2026-10-18
+`
+	eq(t, expected, stdout.String())
+
+	stdout.Reset()
+	eq(t, exitOK, run([]string{"scores", "--file", path, "--mode", "sandbox"}, &stdout, &stderr))
+	eq(t, "No high scores yet.\n", stdout.String())
+}
@@ -0,0 +1,233 @@
+package autorepeat
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/opennikish/tetris/internal/game"
+)
+
+// Config of the auto-repeat timings.
+type Config struct {
+	DAS            time.Duration // delayed auto shift, how long to hold a key before it starts repeating
+	ARR            time.Duration // auto repeat rate, the interval between repeats, zero moves instantly to the wall
+	SoftDropFactor int           // how many times soft drop is faster than gravity
+}
+
+func DefaultConfig() Config {
+	return Config{
+		DAS:            167 * time.Millisecond,
+		ARR:            33 * time.Millisecond,
+		SoftDropFactor: 20,
+	}
+}
+
+func (c Config) Validate() error {
+	if c.DAS < 0 {
+		return fmt.Errorf("das must not be negative, got %s", c.DAS)
+	}
+	if c.ARR < 0 {
+		return fmt.Errorf("arr must not be negative, got %s", c.ARR)
+	}
+	if c.SoftDropFactor < 1 {
+		return fmt.Errorf("soft drop factor must be at least 1, got %d", c.SoftDropFactor)
+	}
+	return nil
+}
+
+// Without key release events a held key is recognized by the repeats the terminal sends on its own:
+// the first one comes after the OS repeat delay, the next ones come every repeat interval.
+// A press coming faster than the OS delay is a new tap, a repeat missing for too long means the key was released.
+const (
+	minRepeatDelay = 150 * time.Millisecond
+	maxRepeatDelay = 700 * time.Millisecond
+	maxRepeatGap   = 150 * time.Millisecond
+)
+
+// instantShift is how many moves ARR of zero makes at once, enough to cross any board.
+const instantShift = 64
+
+type hold struct {
+	cmd         game.Command
+	pressedAt   time.Time
+	lastEvent   time.Time
+	confirmed   bool // known to be held rather than tapped
+	confirmedAt time.Time
+	fired       int
+}
+
+// Repeater tracks held keys and turns them into repeated commands by the game clock.
+// Only horizontal moves repeat, held soft drop speeds up gravity instead.
+type Repeater struct {
+	cfg           Config
+	now           func() time.Time
+	releaseEvents bool
+	shift         *hold
+	shadowed      *hold // the other direction held before shift, it takes over when shift is released
+	softDrop      *hold
+}
+
+func New(cfg Config, now func() time.Time) *Repeater {
+	return &Repeater{
+		cfg: cfg,
+		now: now,
+	}
+}
+
+// UseReleaseEvents switches from guessing held keys by the terminal key repeat to explicit Release calls.
+func (r *Repeater) UseReleaseEvents() {
+	r.releaseEvents = true
+}
+
+// Press registers a key press and tells whether the command should be performed right away.
+// Key repeats sent by the terminal for a held key are swallowed, the repeater produces its own ones in Update.
+func (r *Repeater) Press(cmd game.Command) bool {
+	now := r.now()
+
+	switch cmd {
+	case game.MoveLeft, game.MoveRight:
+		prev := r.shift
+		var perform bool
+		r.shift, perform = r.press(r.shift, cmd, now)
+		if r.releaseEvents && prev != nil && prev.cmd != cmd {
+			r.shadowed = prev
+		}
+		return perform
+	case game.SoftDrop:
+		var perform bool
+		r.softDrop, perform = r.press(r.softDrop, cmd, now)
+		return perform
+	}
+
+	return true
+}
+
+func (r *Repeater) press(h *hold, cmd game.Command, now time.Time) (*hold, bool) {
+	if !r.releaseEvents && h != nil && h.cmd == cmd && r.isRepeat(h, now) {
+		if !h.confirmed {
+			h.confirmed, h.confirmedAt = true, now
+		}
+		h.lastEvent = now
+		return h, false
+	}
+
+	return &hold{
+		cmd:         cmd,
+		pressedAt:   now,
+		lastEvent:   now,
+		confirmed:   r.releaseEvents,
+		confirmedAt: now,
+	}, true
+}
+
+func (r *Repeater) isRepeat(h *hold, now time.Time) bool {
+	if h.confirmed {
+		return now.Sub(h.lastEvent) <= maxRepeatGap
+	}
+	return now.Sub(h.pressedAt) >= minRepeatDelay
+}
+
+// Release registers a key release, only terminals reporting key releases call it.
+// Releasing a direction while the other one is still held lets the other one repeat again after DAS.
+func (r *Repeater) Release(cmd game.Command) {
+	if r.shadowed != nil && r.shadowed.cmd == cmd {
+		r.shadowed = nil
+	}
+	if r.shift != nil && r.shift.cmd == cmd {
+		r.shift = nil
+		if h := r.shadowed; h != nil {
+			now := r.now()
+			r.shift = &hold{cmd: h.cmd, pressedAt: now, lastEvent: now, confirmed: true, confirmedAt: now}
+			r.shadowed = nil
+		}
+	}
+	if r.softDrop != nil && cmd == game.SoftDrop {
+		r.softDrop = nil
+	}
+}
+
+// Update returns the repeated commands due by now. The repeats start DAS after the press, a hold guessed
+// by the terminal repeat starts repeating no earlier than it's confirmed rather than catching up at once.
+// ARR of zero shifts to the wall once per hold, see Recharge.
+func (r *Repeater) Update() []game.Command {
+	now := r.now()
+	r.shift = r.expire(r.shift, now)
+	r.softDrop = r.expire(r.softDrop, now)
+
+	h := r.shift
+	if h == nil || !h.confirmed {
+		return nil
+	}
+	start := h.pressedAt.Add(r.cfg.DAS)
+	if h.confirmedAt.After(start) {
+		start = h.confirmedAt
+	}
+	if now.Before(start) {
+		return nil
+	}
+
+	if r.cfg.ARR == 0 {
+		if h.fired > 0 {
+			return nil
+		}
+		h.fired = 1
+		cmds := make([]game.Command, instantShift)
+		for i := range cmds {
+			cmds[i] = h.cmd
+		}
+		return cmds
+	}
+
+	due := 1 + int(now.Sub(start)/r.cfg.ARR)
+	cmds := make([]game.Command, 0, due-h.fired)
+	for ; h.fired < due; h.fired++ {
+		cmds = append(cmds, h.cmd)
+	}
+	return cmds
+}
+
+func (r *Repeater) expire(h *hold, now time.Time) *hold {
+	if h == nil || r.releaseEvents {
+		return h
+	}
+
+	if h.confirmed && now.Sub(h.lastEvent) > maxRepeatGap {
+		return nil
+	}
+	if !h.confirmed && now.Sub(h.pressedAt) > maxRepeatDelay {
+		return nil
+	}
+	return h
+}
+
+// Recharge lets the held key shift the next tetromino to the wall too when ARR is zero.
+func (r *Repeater) Recharge() {
+	if r.shift != nil && r.cfg.ARR == 0 {
+		r.shift.fired = 0
+	}
+}
+
+// SoftDropping reports whether the soft drop key is held.
+func (r *Repeater) SoftDropping() bool {
+	return r.softDrop != nil && r.softDrop.confirmed
+}
+
+// Gravity returns the gravity interval adjusted for the held soft drop.
+func (r *Repeater) Gravity(base time.Duration) time.Duration {
+	if !r.SoftDropping() {
+		return base
+	}
+	return base / time.Duration(r.cfg.SoftDropFactor)
+}
+
+// SetConfig changes the timings, keys being held keep their state.
+func (r *Repeater) SetConfig(cfg Config) {
+	r.cfg = cfg
+}
+
+// Reset forgets the held keys, e.g. when a new game starts.
+func (r *Repeater) Reset() {
+	r.shift = nil
+	r.shadowed = nil
+	r.softDrop = nil
+}
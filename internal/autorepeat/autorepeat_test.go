@@ -0,0 +1,141 @@
+package autorepeat
+
+import (
+	"testing"
+	"time"
+
+	"github.com/opennikish/tetris/internal/game"
+)
+
+type fakeClock struct {
+	t time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.t
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.t = c.t.Add(d)
+}
+
+func TestRepeatAfterDASWithReleaseEvents(t *testing.T) {
+	clock := &fakeClock{}
+	r := New(Config{DAS: 100 * time.Millisecond, ARR: 20 * time.Millisecond, SoftDropFactor: 10}, clock.Now)
+	r.UseReleaseEvents()
+
+	eq(t, true, r.Press(game.MoveLeft))
+	eq(t, 0, len(r.Update()))
+
+	clock.Advance(99 * time.Millisecond)
+	eq(t, 0, len(r.Update()))
+
+	clock.Advance(1 * time.Millisecond)
+	eq(t, 1, len(r.Update()))
+
+	clock.Advance(50 * time.Millisecond)
+	cmds := r.Update()
+	eq(t, 2, len(cmds))
+	eq(t, game.MoveLeft, cmds[0])
+
+	r.Release(game.MoveLeft)
+	clock.Advance(50 * time.Millisecond)
+	eq(t, 0, len(r.Update()))
+}
+
+func TestNewDirectionReplacesHeldOne(t *testing.T) {
+	clock := &fakeClock{}
+	r := New(Config{DAS: 100 * time.Millisecond, ARR: 0, SoftDropFactor: 10}, clock.Now)
+	r.UseReleaseEvents()
+
+	r.Press(game.MoveLeft)
+	clock.Advance(50 * time.Millisecond)
+	eq(t, true, r.Press(game.MoveRight))
+
+	clock.Advance(60 * time.Millisecond)
+	eq(t, 0, len(r.Update()))
+
+	clock.Advance(40 * time.Millisecond)
+	cmds := r.Update()
+	eq(t, instantShift, len(cmds))
+	eq(t, game.MoveRight, cmds[0])
+
+	r.Release(game.MoveLeft)
+	eq(t, 0, len(r.Update())) // already at the wall
+
+	r.Recharge()
+	eq(t, instantShift, len(r.Update())) // the next tetromino
+}
+
+func TestReleasedDirectionFallsBackToHeldOne(t *testing.T) {
+	clock := &fakeClock{}
+	r := New(Config{DAS: 100 * time.Millisecond, ARR: 20 * time.Millisecond, SoftDropFactor: 10}, clock.Now)
+	r.UseReleaseEvents()
+
+	r.Press(game.MoveLeft)
+	clock.Advance(150 * time.Millisecond)
+	eq(t, 3, len(r.Update()))
+
+	eq(t, true, r.Press(game.MoveRight))
+	clock.Advance(50 * time.Millisecond)
+	r.Release(game.MoveRight)
+	eq(t, 0, len(r.Update()))
+
+	clock.Advance(100 * time.Millisecond) // left is still held, it repeats again after DAS
+	cmds := r.Update()
+	eq(t, 1, len(cmds))
+	eq(t, game.MoveLeft, cmds[0])
+
+	r.Release(game.MoveLeft)
+	clock.Advance(100 * time.Millisecond)
+	eq(t, 0, len(r.Update()))
+}
+
+func TestHeldKeyGuessedByTerminalRepeat(t *testing.T) {
+	clock := &fakeClock{}
+	r := New(Config{DAS: 100 * time.Millisecond, ARR: 20 * time.Millisecond, SoftDropFactor: 10}, clock.Now)
+
+	eq(t, true, r.Press(game.MoveRight))
+	clock.Advance(50 * time.Millisecond)
+	eq(t, true, r.Press(game.MoveRight)) // quick second tap
+
+	clock.Advance(200 * time.Millisecond)
+	eq(t, 0, len(r.Update())) // no terminal repeat yet, might be a tap
+
+	eq(t, false, r.Press(game.MoveRight)) // first terminal repeat confirms the hold
+	eq(t, 1, len(r.Update()))             // no catch up for the time past DAS
+
+	for range 3 {
+		clock.Advance(30 * time.Millisecond)
+		eq(t, false, r.Press(game.MoveRight))
+	}
+	eq(t, 4, len(r.Update()))
+
+	clock.Advance(maxRepeatGap + time.Millisecond)
+	eq(t, 0, len(r.Update())) // repeats stopped, the key is released
+	eq(t, true, r.Press(game.MoveRight))
+}
+
+func TestSoftDropSpeedsUpGravity(t *testing.T) {
+	clock := &fakeClock{}
+	r := New(Config{DAS: 100 * time.Millisecond, ARR: 20 * time.Millisecond, SoftDropFactor: 10}, clock.Now)
+
+	eq(t, true, r.Press(game.SoftDrop))
+	eq(t, 500*time.Millisecond, r.Gravity(500*time.Millisecond))
+
+	clock.Advance(300 * time.Millisecond)
+	eq(t, false, r.Press(game.SoftDrop))
+	eq(t, 50*time.Millisecond, r.Gravity(500*time.Millisecond))
+	eq(t, 0, len(r.Update()))
+
+	clock.Advance(maxRepeatGap + time.Millisecond)
+	r.Update()
+	eq(t, 500*time.Millisecond, r.Gravity(500*time.Millisecond))
+}
+
+func eq[T comparable](t *testing.T, expected, actual T) {
+	t.Helper()
+	if expected != actual {
+		t.Fatalf("expected: %v got: %v", expected, actual)
+	}
+}
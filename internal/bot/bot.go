@@ -0,0 +1,123 @@
+// Package bot plays the game: it rates every placement of the falling tetromino by the features
+// of the board it leaves and returns the commands reaching the best one.
+package bot
+
+import (
+	"math"
+
+	"github.com/opennikish/tetris/internal/game"
+)
+
+// Weights of the board features, the higher the rating the better the placement.
+type Weights struct {
+	Height    float64 // sum of the column heights
+	Lines     float64 // lines completed by the placement
+	Holes     float64 // empty cells with a block above them
+	Bumpiness float64 // sum of the height differences of neighbour columns
+	Wells     float64 // sum of the depths of columns lower than both neighbours
+}
+
+// DefaultWeights are the well-known weights tuned by a genetic algorithm, plus a small penalty for wells.
+func DefaultWeights() Weights {
+	return Weights{
+		Height:    -0.510066,
+		Lines:     0.760666,
+		Holes:     -0.35663,
+		Bumpiness: -0.184483,
+		Wells:     -0.05,
+	}
+}
+
+type Bot struct {
+	weights Weights
+}
+
+func New(weights Weights) *Bot {
+	return &Bot{weights: weights}
+}
+
+// Features describe the board after a placement.
+type Features struct {
+	Height    int
+	Lines     int
+	Holes     int
+	Bumpiness int
+	Wells     int
+}
+
+// Plan returns the commands taking the tetromino to its best placement, tucks and spins included.
+// The commands are tried the way the game applies them, so they reach the placement unless the gravity interferes.
+func (b *Bot) Plan(pf *game.Playfield, tetro *game.Tetromino) []game.Command {
+	var best []game.Command
+	bestRating := 0.0
+
+	for _, p := range pf.Placements(tetro) {
+		rating := b.Rate(Measure(pf, p.Tetromino))
+		if pf.IsHidden(p.Tetromino) {
+			rating = math.Inf(-1) // locks out
+		}
+		if best == nil || rating > bestRating {
+			best, bestRating = p.Path, rating
+		}
+	}
+	return best
+}
+
+// Rate weighs the features.
+func (b *Bot) Rate(f Features) float64 {
+	w := b.weights
+	return w.Height*float64(f.Height) +
+		w.Lines*float64(f.Lines) +
+		w.Holes*float64(f.Holes) +
+		w.Bumpiness*float64(f.Bumpiness) +
+		w.Wells*float64(f.Wells)
+}
+
+// Measure locks the tetromino on a copy of the field and takes the features of the result.
+func Measure(pf *game.Playfield, landed *game.Tetromino) Features {
+	pf = pf.Clone()
+	pf.LockDown(landed)
+	f := Features{Lines: len(pf.RemoveCompletedLines())}
+
+	w, h := pf.Width(), pf.Height()
+	heights := make([]int, w)
+	for j := range w {
+		top := h
+		for i := range h {
+			if pf.Cell(i, j) == game.CellBlock {
+				if top == h {
+					top = i
+				}
+			} else if top < h {
+				f.Holes++
+			}
+		}
+		heights[j] = h - top
+		f.Height += heights[j]
+	}
+
+	for j := range w {
+		if j+1 < w {
+			f.Bumpiness += abs(heights[j] - heights[j+1])
+		}
+
+		left, right := h, h // walls are as high as the field
+		if j > 0 {
+			left = heights[j-1]
+		}
+		if j+1 < w {
+			right = heights[j+1]
+		}
+		if depth := min(left, right) - heights[j]; depth > 0 {
+			f.Wells += depth
+		}
+	}
+	return f
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
@@ -0,0 +1,70 @@
+package bot
+
+import (
+	"testing"
+
+	"github.com/opennikish/tetris/internal/game"
+)
+
+func TestMeasureFeatures(t *testing.T) {
+	pf := game.NewPlayfield(4, 4)
+	for j := range 3 {
+		pf.ToggleCell(3, j)
+	}
+	o := game.NewOTetro()
+	o.MoveHoriz(-4)
+	o.MoveVert(2) // on top of the bottom line in the left corner
+
+	f := Measure(pf, o)
+
+	eq(t, Features{Height: 7, Lines: 0, Holes: 0, Bumpiness: 3, Wells: 1}, f)
+	eq(t, game.CellEmpty, pf.Cell(2, 0)) // the field itself stays as it is
+}
+
+func TestMeasureCountsHoles(t *testing.T) {
+	pf := game.NewPlayfield(6, 4)
+	pf.ToggleCell(3, 0)
+	i := game.NewITetro()
+	i.MoveHoriz(-3)
+	i.MoveVert(3) // flat on the single block, over three empty cells
+
+	f := Measure(pf, i)
+
+	eq(t, 3, f.Holes)
+	eq(t, 8, f.Height)
+}
+
+func TestPlanFillsTheGap(t *testing.T) {
+	sim := game.NewSim(func(n int) int { return 1 }, game.Options{Width: 4, Height: 4})
+	for j := range 3 {
+		sim.Gameplay().Field().ToggleCell(3, j)
+	}
+
+	g := sim.Gameplay()
+	sim.Step(New(DefaultWeights()).Plan(g.Field(), g.CurrentTetromino())...)
+
+	eq(t, 1, sim.Snapshot().Lines)
+}
+
+func TestBotPlaysLongGame(t *testing.T) {
+	bot := New(DefaultWeights())
+	sim := game.NewSim(game.SeededRand(1), game.Options{})
+
+	for !sim.Over() && sim.Snapshot().Pieces < 300 {
+		g := sim.Gameplay()
+		sim.Step(bot.Plan(g.Field(), g.CurrentTetromino())...)
+	}
+
+	s := sim.Snapshot()
+	eq(t, false, s.Over)
+	if s.Lines < 100 {
+		t.Fatalf("expected at least 100 lines of 300 pieces, got %d", s.Lines)
+	}
+}
+
+func eq[T comparable](t *testing.T, expected, actual T) {
+	t.Helper()
+	if expected != actual {
+		t.Fatalf("expected: %v got: %v", expected, actual)
+	}
+}
@@ -0,0 +1,66 @@
+package game
+
+// FinesseFaultEvent tells the tetromino took more key presses than its position needs.
+type FinesseFaultEvent struct {
+	Inputs  int // presses made
+	Minimal int // presses needed
+}
+
+func (e FinesseFaultEvent) IsEvent() {}
+
+// Finesse returns the least number of key presses taking the tetromino to the landed position: a move,
+// a rotation or a held key shifting it to the wall. Drops are free as the gravity makes them anyway.
+// False if the position can't be reached.
+func (pf *Playfield) Finesse(tetro, landed *Tetromino) (int, bool) {
+	type state struct {
+		points   [4]Point
+		rotation int
+	}
+
+	// The drops cost nothing, so they extend the layer of the same cost and the rest makes the next layer.
+	done := map[state]bool{}
+	layer := []*Tetromino{tetro.Clone()}
+	for cost := 0; len(layer) > 0; cost++ {
+		var next []*Tetromino
+		for i := 0; i < len(layer); i++ {
+			t := layer[i]
+			s := state{t.Points, t.rotationPos}
+			if done[s] {
+				continue
+			}
+			done[s] = true
+
+			if t.Points == landed.Points {
+				return cost, true
+			}
+
+			if dropped, ok := pf.Apply(t, SoftDrop); ok {
+				layer = append(layer, dropped)
+			}
+			for _, cmd := range []Command{Rotate, MoveLeft, MoveRight} {
+				if moved, ok := pf.Apply(t, cmd); ok {
+					next = append(next, moved)
+				}
+			}
+			for _, cmd := range []Command{MoveLeft, MoveRight} {
+				if shifted := pf.shift(t, cmd); shifted.Points != t.Points {
+					next = append(next, shifted)
+				}
+			}
+		}
+		layer = next
+	}
+
+	return 0, false
+}
+
+// shift moves the tetromino as far as it goes, the way a held key does.
+func (pf *Playfield) shift(tetro *Tetromino, cmd Command) *Tetromino {
+	for {
+		moved, ok := pf.Apply(tetro, cmd)
+		if !ok {
+			return tetro
+		}
+		tetro = moved
+	}
+}
@@ -0,0 +1,93 @@
+package game
+
+import "testing"
+
+func TestFinesseCountsMovesAndRotations(t *testing.T) {
+	pf := NewPlayfield(10, 20)
+	tetro := NewTTetro()
+
+	landed := tetro.Clone()
+	landed.MoveHoriz(-2)
+	landed.Rotate()
+	landed.MoveVert(18)
+	minimal, ok := pf.Finesse(tetro, landed)
+
+	eq(t, true, ok)
+	eq(t, 3, minimal)
+}
+
+func TestFinesseCountsShiftToWallOnce(t *testing.T) {
+	pf := NewPlayfield(10, 20)
+	tetro := NewTTetro()
+
+	landed := tetro.Clone()
+	landed.MoveHoriz(-3)
+	landed.Rotate()
+	landed.MoveVert(18)
+	minimal, ok := pf.Finesse(tetro, landed)
+
+	eq(t, true, ok)
+	eq(t, 2, minimal) // rotate, hold left
+}
+
+func TestFinesseOfTuckCountsSlideOnly(t *testing.T) {
+	pf := NewPlayfield(10, 6)
+	for j := range 3 {
+		pf.ToggleCell(3, j)
+	}
+	tetro := NewOTetro()
+
+	tucked := tetro.Clone()
+	tucked.MoveHoriz(-3)
+	tucked.MoveVert(5)
+	minimal, ok := pf.Finesse(tetro, tucked)
+
+	eq(t, true, ok)
+	eq(t, 2, minimal) // hold left under the ledge to the wall, tap right
+}
+
+func TestFinesseOfUnreachablePosition(t *testing.T) {
+	pf := NewPlayfield(10, 6)
+	for j := range 10 {
+		pf.ToggleCell(3, j)
+	}
+	tetro := NewOTetro()
+
+	buried := tetro.Clone()
+	buried.MoveVert(5)
+	_, ok := pf.Finesse(tetro, buried)
+
+	eq(t, false, ok)
+}
+
+func TestGameplayReportsFinesseFault(t *testing.T) {
+	gp := NewGameplay(func(n int) int { return 0 }, Options{})
+	press := func(cmds ...Command) {
+		for _, cmd := range cmds {
+			gp.CountInput(cmd)
+			gp.HandleCommand(cmd)
+		}
+	}
+
+	press(MoveLeft, MoveRight, MoveLeft, HardDrop)
+	events := gp.Update()
+
+	eq(t, Event(FinesseFaultEvent{Inputs: 3, Minimal: 1}), events[1])
+	eq(t, 1, gp.Faults())
+
+	press(MoveLeft, HardDrop)
+	gp.Update()
+	eq(t, 1, gp.Faults())
+
+	// the repeats of a held key aren't presses
+	press(MoveLeft)
+	for gp.HandleCommand(MoveLeft) {
+	}
+	press(HardDrop)
+	gp.Update()
+	eq(t, 1, gp.Faults())
+
+	press(Rotate, Rotate, Rotate, Rotate, HardDrop) // back where it was, but the presses count
+	gp.Update()
+	eq(t, 2, gp.Faults())
+}
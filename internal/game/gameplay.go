@@ -1,5 +1,11 @@
 package game
 
+import (
+	"fmt"
+	"io"
+	"log/slog"
+)
+
 type Command int
 
 const (
@@ -7,6 +13,7 @@ const (
 	MoveRight
 	Rotate
 	HardDrop
+	SoftDrop
 )
 
 var cmdNames = map[Command]string{
@@ -14,42 +21,170 @@ var cmdNames = map[Command]string{
 	MoveRight: "move-right",
 	Rotate:    "rotate",
 	HardDrop:  "hard-drop",
+	SoftDrop:  "soft-drop",
 }
 
 func (c Command) String() string {
 	return cmdNames[c]
 }
 
+// ParseCommand is the reverse of Command.String.
+func ParseCommand(s string) (Command, bool) {
+	for cmd, name := range cmdNames {
+		if name == s {
+			return cmd, true
+		}
+	}
+	return 0, false
+}
+
+const (
+	DefaultWidth  = 10
+	DefaultHeight = 20
+	MinSize       = 4
+	MaxSize       = 40
+	MaxLevel      = 20
+	linesPerLevel = 10
+)
+
+// lineScores are the points for clearing 1-4 lines at once, multiplied by the level.
+var lineScores = [...]int{0, 100, 300, 500, 800}
+
+// Points per cell of a drop made by the player.
+const (
+	softDropScore = 1
+	hardDropScore = 2
+)
+
+// Options of a game, zero values stand for the defaults.
+type Options struct {
+	Width    int
+	Height   int
+	Level    int // starting level
+	LineGoal int // the game is over once this many lines are cleared, zero plays endlessly
+}
+
+func (o Options) withDefaults() Options {
+	if o.Width == 0 {
+		o.Width = DefaultWidth
+	}
+	if o.Height == 0 {
+		o.Height = DefaultHeight
+	}
+	if o.Level == 0 {
+		o.Level = 1
+	}
+	return o
+}
+
+func (o Options) Validate() error {
+	o = o.withDefaults()
+	if o.Width < MinSize || o.Width > MaxSize {
+		return fmt.Errorf("width must be in range %d..%d, got %d", MinSize, MaxSize, o.Width)
+	}
+	if o.Height < MinSize || o.Height > MaxSize {
+		return fmt.Errorf("height must be in range %d..%d, got %d", MinSize, MaxSize, o.Height)
+	}
+	if o.Level < 1 || o.Level > MaxLevel {
+		return fmt.Errorf("level must be in range 1..%d, got %d", MaxLevel, o.Level)
+	}
+	if o.LineGoal < 0 {
+		return fmt.Errorf("line goal must not be negative, got %d", o.LineGoal)
+	}
+	return nil
+}
+
 type Gameplay struct {
-	rand      func(n int) int
-	playfield *Playfield
-	currTetro *Tetromino
+	rand        func(n int) int
+	garbageRand func(n int) int // the holes of the garbage, apart from the tetrominoes so garbage doesn't change their order
+	opts        Options
+	playfield   *Playfield
+	currTetro   *Tetromino
+	queue       []*Tetromino
+	lines       int
+	score       int
+	pieces      int
+	faults      int
+	spawned     *Tetromino // the current tetromino as it spawned
+	inputs      int        // key presses moving and rotating the current tetromino
+	garbage     []int      // queued garbage lines, attack by attack
+	combo       int        // clears in a row
+	backToBack  bool       // the last clear was a tetris
+	logger      *slog.Logger
 }
 
-func NewGameplay(rand func(n int) int) *Gameplay {
+// NewGameplay starts a game, the options are expected to be validated.
+func NewGameplay(rand func(n int) int, opts Options) *Gameplay {
+	opts = opts.withDefaults()
 	gp := &Gameplay{
-		rand:      rand,
-		playfield: NewPlayfield(10, 20),
+		rand:        rand,
+		garbageRand: GarbageRand(0),
+		opts:        opts,
+		playfield:   NewPlayfield(opts.Width, opts.Height),
+		logger:      slog.New(slog.NewTextHandler(io.Discard, nil)),
 	}
-	gp.currTetro = gp.nextTetro()
+	gp.takeNext()
 	return gp
 }
 
+// SetGarbageRand picks the holes of the garbage lines, see GarbageRand.
+func (g *Gameplay) SetGarbageRand(rand func(n int) int) {
+	g.garbageRand = rand
+}
+
+// SetLogger sends the game events to the logger, they are discarded by default.
+func (g *Gameplay) SetLogger(l *slog.Logger) {
+	g.logger = l
+}
+
 func (g *Gameplay) Update() []Event {
 	events := []Event{}
 	if g.playfield.IsLanded(g.currTetro) {
+		lockOut := g.playfield.IsAbove(g.currTetro)
+		minimal, reachable := g.playfield.Finesse(g.spawned, g.currTetro)
 		g.playfield.LockDown(g.currTetro)
+		g.pieces++
 		events = append(events, TetroLockedEvent{})
 
+		if reachable && g.inputs > minimal {
+			g.faults++
+			g.logger.Debug("finesse fault", "inputs", g.inputs, "minimal", minimal, "faults", g.faults)
+			events = append(events, FinesseFaultEvent{Inputs: g.inputs, Minimal: minimal})
+		}
+
 		completed := g.playfield.RemoveCompletedLines()
+		g.score += lineScores[len(completed)] * g.Level()
+		g.lines += len(completed)
+		g.logger.Debug("tetromino locked", "pieces", g.pieces, "cleared", len(completed), "score", g.score)
+
+		topOut := false
+		if attack := g.cancel(g.attack(len(completed))); attack > 0 {
+			events = append(events, AttackEvent{Lines: attack})
+		}
+		if len(completed) == 0 && len(g.garbage) > 0 {
+			raised, fits := g.raiseGarbage()
+			topOut = !fits
+			g.logger.Debug("garbage", "lines", raised, "pending", g.PendingGarbage())
+			events = append(events, GarbageEvent{Lines: raised})
+		}
 		events = append(events, LinesUpdatedEvent{
 			Cleared: map_(completed, func(l int) int { return l - 1 }),
 		})
 
-		g.currTetro = g.nextTetro()
+		g.takeNext()
 
-		if !g.playfield.CanPlace(g.currTetro) {
-			events = append(events, GameOverEvent{})
+		switch {
+		case g.GoalReached():
+			events = append(events, GameOverEvent{Reason: ReasonGoal})
+		case lockOut:
+			events = append(events, GameOverEvent{Reason: ReasonLockOut})
+		case topOut:
+			events = append(events, GameOverEvent{Reason: ReasonTopOut})
+		case !g.playfield.CanPlace(g.currTetro):
+			events = append(events, GameOverEvent{Reason: ReasonBlockOut})
+		}
+		if e, ok := events[len(events)-1].(GameOverEvent); ok {
+			g.logger.Info("game over", "reason", e.Reason, "score", g.score, "lines", g.lines, "pieces", g.pieces)
 		}
 	}
 
@@ -58,30 +193,31 @@ func (g *Gameplay) Update() []Event {
 	return events
 }
 
-func (g *Gameplay) HandleCommand(cmd Command) {
+// HandleCommand moves the current tetromino, it reports false and changes nothing if the tetromino can't move.
+func (g *Gameplay) HandleCommand(cmd Command) bool {
+	next, ok := g.playfield.Apply(g.currTetro, cmd)
+	if !ok {
+		return false
+	}
+
 	switch cmd {
-	case Rotate:
-		cand := g.currTetro.Clone()
-		cand.Rotate()
-		if g.playfield.CanPlace(cand) {
-			g.currTetro = cand
-		}
-	case MoveLeft:
-		g.currTetro.MoveHoriz(-1)
-		if !g.playfield.CanPlace(g.currTetro) {
-			g.currTetro.MoveHoriz(1)
-		}
-	case MoveRight:
-		g.currTetro.MoveHoriz(1)
-		if !g.playfield.CanPlace(g.currTetro) {
-			g.currTetro.MoveHoriz(-1)
-		}
+	case SoftDrop:
+		g.score += softDropScore
 	case HardDrop:
-		for g.playfield.CanPlace(g.currTetro) {
-			g.currTetro.MoveVert(1)
-		}
-		g.currTetro.MoveVert(-1)
+		g.score += hardDropScore * (next.Points[0].Y - g.currTetro.Points[0].Y)
+	}
+	g.currTetro = next
+	return true
+}
+
+// CountInput counts a key press for the finesse of the current tetromino and reports whether it counts:
+// moves and rotations do, even if they fail, drops are free. The auto repeat of a held key isn't a press.
+func (g *Gameplay) CountInput(cmd Command) bool {
+	if cmd != MoveLeft && cmd != MoveRight && cmd != Rotate {
+		return false
 	}
+	g.inputs++
+	return true
 }
 
 func (g *Gameplay) CurrentTetromino() *Tetromino {
@@ -92,7 +228,82 @@ func (g *Gameplay) Field() *Playfield {
 	return g.playfield
 }
 
+// Score follows the guideline: line clears are worth more at higher levels, drops by the player add a bit.
+func (g *Gameplay) Score() int {
+	return g.score
+}
+
+// GoalReached reports whether the line goal of the options is reached.
+func (g *Gameplay) GoalReached() bool {
+	return g.opts.LineGoal > 0 && g.lines >= g.opts.LineGoal
+}
+
+// Pieces returns the number of locked tetrominoes.
+func (g *Gameplay) Pieces() int {
+	return g.pieces
+}
+
+// Lines returns the number of cleared lines.
+func (g *Gameplay) Lines() int {
+	return g.lines
+}
+
+// Faults returns the number of tetrominoes moved and rotated more than needed, see Playfield.Finesse.
+func (g *Gameplay) Faults() int {
+	return g.faults
+}
+
+// Level goes up every 10 cleared lines starting from the level of the options.
+func (g *Gameplay) Level() int {
+	return min(g.opts.Level+g.lines/linesPerLevel, MaxLevel)
+}
+
+// Preview returns the next n tetrominoes in the order they come.
+func (g *Gameplay) Preview(n int) []*Tetromino {
+	for len(g.queue) < n {
+		g.queue = append(g.queue, g.spawnTetro())
+	}
+
+	next := make([]*Tetromino, n)
+	for i := range n {
+		next[i] = g.queue[i].Clone()
+	}
+	return next
+}
+
+// Ghost returns the current tetromino dropped as low as it goes, i.e. where a hard drop puts it.
+func (g *Gameplay) Ghost() *Tetromino {
+	ghost := g.currTetro.Clone()
+	for g.playfield.CanPlace(ghost) {
+		ghost.MoveVert(1)
+	}
+	ghost.MoveVert(-1)
+	return ghost
+}
+
+// takeNext makes the next tetromino the current one.
+func (g *Gameplay) takeNext() {
+	g.currTetro = g.nextTetro()
+	g.spawned = g.currTetro.Clone()
+	g.inputs = 0
+}
+
 func (g *Gameplay) nextTetro() *Tetromino {
+	if len(g.queue) > 0 {
+		tetro := g.queue[0]
+		g.queue = g.queue[1:]
+		return tetro
+	}
+	return g.spawnTetro()
+}
+
+func (g *Gameplay) spawnTetro() *Tetromino {
+	tetro := g.randomTetro()
+	tetro.MoveHoriz((g.playfield.Width() - DefaultWidth) / 2) // spawn positions are for the default width
+	return tetro
+}
+
+func (g *Gameplay) randomTetro() *Tetromino {
 	switch g.rand(7) {
 	case 0:
 		return NewTTetro()
@@ -128,8 +339,19 @@ type LinesUpdatedEvent struct {
 func (e LinesUpdatedEvent) IsEvent() {}
 
 type GameOverEvent struct {
+	Reason GameOverReason
 }
 
+// GameOverReason tells why the game is over, it reads well in the game-over screen.
+type GameOverReason string
+
+const (
+	ReasonBlockOut GameOverReason = "block out" // no room for the next tetromino
+	ReasonLockOut  GameOverReason = "lock out"  // the tetromino locked above the visible field
+	ReasonTopOut   GameOverReason = "top out"   // garbage pushed blocks above the field
+	ReasonGoal     GameOverReason = "goal reached"
+)
+
 func (e GameOverEvent) IsEvent() {}
 
 func map_[T any, R any](in []T, fn func(T) R) []R {
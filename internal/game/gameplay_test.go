@@ -0,0 +1,151 @@
+package game
+
+import (
+	"testing"
+)
+
+func TestNewGameplayDefaults(t *testing.T) {
+	gp := NewGameplay(func(n int) int { return 0 }, Options{})
+
+	eq(t, DefaultWidth, gp.Field().Width())
+	eq(t, DefaultHeight, gp.Field().Height())
+	eq(t, 1, gp.Level())
+	eq(t, 0, gp.Lines())
+}
+
+func TestSpawnIsCentredOnNarrowField(t *testing.T) {
+	gp := NewGameplay(func(n int) int { return 1 }, Options{Width: 4, Height: 6})
+
+	expected := [4]Point{{0, 0}, {1, 0}, {2, 0}, {3, 0}}
+	eq(t, expected, gp.CurrentTetromino().Points)
+}
+
+func TestLineGoalEndsGame(t *testing.T) {
+	gp := NewGameplay(func(n int) int { return 1 }, Options{Width: 4, Height: 4, Level: 3, LineGoal: 1})
+
+	var events []Event
+	for range 6 {
+		events = gp.Update()
+		if len(events) > 0 {
+			break
+		}
+	}
+
+	eq(t, 1, gp.Lines())
+	eq(t, 3, gp.Level())
+	eq(t, 1, gp.Pieces())
+	eq(t, Event(GameOverEvent{Reason: ReasonGoal}), events[len(events)-1])
+}
+
+func TestStackedTetrominoesBlockOut(t *testing.T) {
+	gp := NewGameplay(func(n int) int { return 2 }, Options{Width: 4, Height: 4})
+
+	var over Event
+	for range 20 {
+		events := gp.Update()
+		if len(events) > 0 {
+			over = events[len(events)-1]
+		}
+		if _, ok := over.(GameOverEvent); ok {
+			break
+		}
+	}
+
+	eq(t, Event(GameOverEvent{Reason: ReasonBlockOut}), over)
+	eq(t, 2, gp.Pieces())
+}
+
+func TestLockAboveFieldLocksOut(t *testing.T) {
+	gp := NewGameplay(func(n int) int { return 1 }, Options{})
+	gp.Field().ToggleCell(0, 4) // under the I
+
+	events := gp.Update()
+
+	eq(t, Event(GameOverEvent{Reason: ReasonLockOut}), events[len(events)-1])
+}
+
+func TestLockPartlyAboveFieldGoesOn(t *testing.T) {
+	gp := NewGameplay(func(n int) int { return 0 }, Options{})
+	gp.HandleCommand(MoveLeft)
+	gp.HandleCommand(MoveLeft)
+	gp.HandleCommand(MoveLeft)
+	gp.Field().ToggleCell(1, 0) // under the left of the T, its top stays in the hidden line
+
+	events := gp.Update()
+
+	eq(t, Event(TetroLockedEvent{}), events[0])
+	for _, e := range events {
+		if _, ok := e.(GameOverEvent); ok {
+			t.Fatalf("expected the game to go on, got %v", e)
+		}
+	}
+	eq(t, CellBlock, gp.Field().Cell(0, 1))
+}
+
+func TestCommandAgainstWallChangesNothing(t *testing.T) {
+	gp := NewGameplay(func(n int) int { return 0 }, Options{})
+	for gp.HandleCommand(MoveLeft) {
+	}
+	prev := gp.CurrentTetromino().Clone()
+
+	eq(t, false, gp.HandleCommand(MoveLeft))
+	eq(t, prev.Points, gp.CurrentTetromino().Points)
+	eq(t, true, gp.HandleCommand(MoveRight))
+}
+
+func TestOptionsValidate(t *testing.T) {
+	eq(t, nil, Options{}.Validate())
+	eq(t, "width must be in range 4..40, got 3", Options{Width: 3}.Validate().Error())
+	eq(t, "level must be in range 1..20, got 21", Options{Level: 21}.Validate().Error())
+}
+
+func TestParseCommand(t *testing.T) {
+	for cmd := range cmdNames {
+		parsed, ok := ParseCommand(cmd.String())
+		eq(t, true, ok)
+		eq(t, cmd, parsed)
+	}
+
+	_, ok := ParseCommand("fly")
+	eq(t, false, ok)
+}
+
+func TestPreviewKeepsTheSequence(t *testing.T) {
+	seq := []int{1, 2, 3, 4}
+	rand := func(n int) int {
+		v := seq[0]
+		seq = seq[1:]
+		return v
+	}
+	gp := NewGameplay(rand, Options{})
+
+	next := gp.Preview(2)
+	eq(t, NewOTetro().Points, next[0].Points)
+	eq(t, NewSTetro().Points, next[1].Points)
+
+	gp.HandleCommand(HardDrop)
+	gp.Update()
+	eq(t, NewOTetro().Points[0].X, gp.CurrentTetromino().Points[0].X)
+	eq(t, NewSTetro().Points, gp.Preview(1)[0].Points)
+}
+
+func TestGhostLandsOnTheFloor(t *testing.T) {
+	gp := NewGameplay(func(n int) int { return 1 }, Options{})
+
+	expected := [4]Point{{3, 20}, {4, 20}, {5, 20}, {6, 20}}
+	eq(t, expected, gp.Ghost().Points)
+	eq(t, [4]Point{{3, 0}, {4, 0}, {5, 0}, {6, 0}}, gp.CurrentTetromino().Points)
+}
+
+func TestScore(t *testing.T) {
+	gp := NewGameplay(func(n int) int { return 1 }, Options{Width: 4, Height: 4, Level: 2})
+
+	gp.HandleCommand(SoftDrop)
+	eq(t, 1, gp.Score())
+	gp.HandleCommand(HardDrop)
+	eq(t, 1+2*3, gp.Score())
+
+	gp.Update()
+	eq(t, 1+2*3+100*2, gp.Score())
+	eq(t, false, gp.GoalReached())
+}
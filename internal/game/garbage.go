@@ -0,0 +1,93 @@
+package game
+
+// attackLines are the garbage lines sent to the opponent for clearing 1-4 lines at once.
+var attackLines = [...]int{0, 0, 1, 2, 4}
+
+// comboLines are added by the number of clears in a row before this one.
+var comboLines = [...]int{0, 1, 1, 2, 2, 3, 3, 4, 4, 4, 5}
+
+const (
+	backToBackLines   = 1  // for a tetris right after a tetris
+	perfectClearLines = 10 // for leaving the field empty
+	garbagePerLock    = 8  // most lines rising at once, the rest waits for the next lock
+)
+
+// AttackEvent tells the garbage lines left to send to the opponent after cancelling the queued ones.
+type AttackEvent struct {
+	Lines int
+}
+
+func (e AttackEvent) IsEvent() {}
+
+// GarbageEvent tells the queued garbage lines rose into the field.
+type GarbageEvent struct {
+	Lines int
+}
+
+func (e GarbageEvent) IsEvent() {}
+
+// ReceiveGarbage queues the lines sent by the opponent, they rise when a tetromino locks without a clear.
+func (g *Gameplay) ReceiveGarbage(lines int) {
+	if lines > 0 {
+		g.garbage = append(g.garbage, lines)
+	}
+}
+
+// PendingGarbage returns the number of the queued garbage lines.
+func (g *Gameplay) PendingGarbage() int {
+	n := 0
+	for _, lines := range g.garbage {
+		n += lines
+	}
+	return n
+}
+
+// attack returns the garbage lines earned by the clear and keeps the combo and back-to-back counting.
+func (g *Gameplay) attack(cleared int) int {
+	if cleared == 0 {
+		g.combo = 0
+		return 0
+	}
+
+	lines := attackLines[cleared] + comboLines[min(g.combo, len(comboLines)-1)]
+	g.combo++
+	if cleared == 4 && g.backToBack {
+		lines += backToBackLines
+	}
+	g.backToBack = cleared == 4
+	if g.playfield.IsEmpty() {
+		lines += perfectClearLines
+	}
+	return lines
+}
+
+// cancel takes the attack off the queued garbage and returns what is left to send.
+func (g *Gameplay) cancel(lines int) int {
+	for lines > 0 && len(g.garbage) > 0 {
+		n := min(lines, g.garbage[0])
+		g.garbage[0] -= n
+		lines -= n
+		if g.garbage[0] == 0 {
+			g.garbage = g.garbage[1:]
+		}
+	}
+	return lines
+}
+
+// raiseGarbage pushes the queued lines into the field, the lines of one attack share the hole.
+// Returns the number of risen lines and false if blocks were pushed out of the top.
+func (g *Gameplay) raiseGarbage() (int, bool) {
+	raised, fits := 0, true
+	for len(g.garbage) > 0 && raised < garbagePerLock {
+		n := min(g.garbage[0], garbagePerLock-raised)
+		if !g.playfield.AddGarbage(n, g.garbageRand(g.playfield.Width())) {
+			fits = false
+		}
+		raised += n
+		g.garbage[0] -= n
+		if g.garbage[0] == 0 {
+			g.garbage = g.garbage[1:]
+		}
+	}
+	return raised, fits
+}
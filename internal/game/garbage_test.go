@@ -0,0 +1,85 @@
+package game
+
+import "testing"
+
+func TestAttackTable(t *testing.T) {
+	gp := NewGameplay(func(n int) int { return 0 }, Options{})
+	gp.Field().ToggleCell(19, 0) // not a perfect clear
+
+	eq(t, 4, gp.attack(4))
+	eq(t, 6, gp.attack(4)) // combo and back-to-back
+	eq(t, 2, gp.attack(2)) // combo
+	eq(t, 0, gp.attack(0))
+	eq(t, 0, gp.attack(1))
+	eq(t, 3, gp.attack(3)) // combo again
+}
+
+func TestPerfectClearAttack(t *testing.T) {
+	gp := NewGameplay(func(n int) int { return 0 }, Options{})
+
+	eq(t, 10, gp.attack(1))
+}
+
+func TestAttackCancelsQueuedGarbage(t *testing.T) {
+	gp := NewGameplay(func(n int) int { return 0 }, Options{})
+	gp.ReceiveGarbage(1)
+	gp.ReceiveGarbage(2)
+
+	eq(t, 0, gp.cancel(2))
+	eq(t, 1, gp.PendingGarbage())
+	eq(t, 2, gp.cancel(3))
+	eq(t, 0, gp.PendingGarbage())
+}
+
+func TestGarbageRisesOnLockWithoutClear(t *testing.T) {
+	gp := NewGameplay(func(n int) int { return 2 }, Options{Width: 4, Height: 6})
+	gp.SetGarbageRand(func(n int) int { return 2 })
+	gp.ReceiveGarbage(2)
+
+	events := lockOne(gp)
+
+	eq(t, Event(GarbageEvent{Lines: 2}), events[1])
+	eq(t, 0, gp.PendingGarbage())
+	expected := "    \n....\n....\n.##.\n.##.\n##.#\n##.#\n"
+	eq(t, expected, gp.Field().String())
+}
+
+func TestGarbageTopsOut(t *testing.T) {
+	gp := NewGameplay(func(n int) int { return 2 }, Options{Width: 4, Height: 4})
+	gp.ReceiveGarbage(3)
+
+	events := lockOne(gp)
+
+	eq(t, Event(GameOverEvent{Reason: ReasonTopOut}), events[len(events)-1])
+}
+
+func TestGarbageAndPreviewKeepTetrominoOrder(t *testing.T) {
+	plain := NewGameplay(SeededRand(5), Options{})
+	attacked := NewGameplay(SeededRand(5), Options{})
+	attacked.SetGarbageRand(GarbageRand(5))
+
+	for i := range 5 {
+		attacked.ReceiveGarbage(1)
+		attacked.Preview(3)
+		eq(t, plain.CurrentTetromino().Points, attacked.CurrentTetromino().Points)
+		for _, gp := range []*Gameplay{plain, attacked} {
+			gp.HandleCommand(HardDrop)
+			lockOne(gp)
+		}
+		if attacked.Field().String() == plain.Field().String() {
+			t.Fatalf("piece %d: expected garbage in the field", i)
+		}
+	}
+	for i, next := range plain.Preview(5) {
+		eq(t, next.Points, attacked.Preview(5)[i].Points)
+	}
+}
+
+// lockOne lets the gravity drop the tetromino until it locks.
+func lockOne(gp *Gameplay) []Event {
+	for {
+		if events := gp.Update(); len(events) > 0 {
+			return events
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package game
+
+// Placement is a resting position of the tetromino, where gravity would lock it,
+// and the shortest commands reaching it.
+type Placement struct {
+	Tetromino *Tetromino
+	Path      []Command
+}
+
+// moves are tried in this order, so among the paths of the same length the ones rotating first win.
+var moves = []Command{Rotate, MoveLeft, MoveRight, HardDrop, SoftDrop}
+
+// Placements lists every distinct resting position the tetromino can reach by the commands, including
+// tucks under overhangs and spins, with the shortest path to each. The closest placements come first.
+func (pf *Playfield) Placements(tetro *Tetromino) []Placement {
+	type state struct {
+		points   [4]Point
+		rotation int
+	}
+	type node struct {
+		tetro *Tetromino
+		path  []Command
+	}
+
+	start := tetro.Clone()
+	seen := map[state]bool{{start.Points, start.rotationPos}: true}
+	queue := []node{{tetro: start}}
+	resting := map[[4]Point]bool{}
+	var placements []Placement
+
+	for len(queue) > 0 {
+		n := queue[0]
+		queue = queue[1:]
+
+		if pf.IsLanded(n.tetro) && !resting[n.tetro.Points] {
+			resting[n.tetro.Points] = true
+			placements = append(placements, Placement{Tetromino: n.tetro, Path: n.path})
+		}
+
+		for _, cmd := range moves {
+			next, ok := pf.Apply(n.tetro, cmd)
+			if !ok {
+				continue
+			}
+			s := state{next.Points, next.rotationPos}
+			if seen[s] {
+				continue
+			}
+			seen[s] = true
+
+			path := make([]Command, len(n.path), len(n.path)+1)
+			copy(path, n.path)
+			queue = append(queue, node{tetro: next, path: append(path, cmd)})
+		}
+	}
+
+	return placements
+}
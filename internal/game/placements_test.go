@@ -0,0 +1,50 @@
+package game
+
+import "testing"
+
+func TestPlacementsOnEmptyField(t *testing.T) {
+	pf := NewPlayfield(10, 20)
+
+	eq(t, 9, len(pf.Placements(NewOTetro())))
+	eq(t, 34, len(pf.Placements(NewTTetro())))
+	eq(t, 17, len(pf.Placements(NewITetro())))
+}
+
+func TestPlacementsTakeShortestPath(t *testing.T) {
+	pf := NewPlayfield(10, 20)
+
+	first := pf.Placements(NewOTetro())[0]
+
+	eq(t, 1, len(first.Path))
+	eq(t, HardDrop, first.Path[0])
+	eq(t, [4]Point{{4, 19}, {5, 19}, {4, 20}, {5, 20}}, first.Tetromino.Points)
+}
+
+func TestPlacementsTuckUnderOverhang(t *testing.T) {
+	pf := NewPlayfield(10, 6)
+	for j := range 3 {
+		pf.ToggleCell(3, j) // a roof over the two bottom lines in the left corner
+	}
+
+	tucked := [4]Point{{1, 5}, {2, 5}, {1, 6}, {2, 6}}
+	var found *Placement
+	for _, p := range pf.Placements(NewOTetro()) {
+		if p.Tetromino.Points == tucked {
+			found = &p
+		}
+	}
+	if found == nil {
+		t.Fatalf("expected a placement under the roof")
+	}
+	eq(t, 4, len(found.Path))
+
+	tetro := NewOTetro()
+	for _, cmd := range found.Path {
+		var ok bool
+		if tetro, ok = pf.Apply(tetro, cmd); !ok {
+			t.Fatalf("command %v of the path can't be applied", cmd)
+		}
+	}
+	eq(t, tucked, tetro.Points)
+	eq(t, true, pf.IsLanded(tetro))
+}
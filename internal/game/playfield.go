@@ -2,6 +2,7 @@ package game
 
 import (
 	"slices"
+	"strings"
 )
 
 type CellKind uint8
@@ -49,6 +50,17 @@ func (pf *Playfield) Cell(i, j int) CellKind {
 	return pf.field[i+1][j]
 }
 
+// ToggleCell flips the visible cell between empty and block, used for editing the board in sandbox.
+func (pf *Playfield) ToggleCell(i, j int) CellKind {
+	cell := &pf.field[i+1][j]
+	if *cell == CellBlock {
+		*cell = CellEmpty
+	} else {
+		*cell = CellBlock
+	}
+	return *cell
+}
+
 func (pf *Playfield) Height() int {
 	return len(pf.field) - 1
 }
@@ -76,6 +88,42 @@ func (pf *Playfield) CanPlace(tetro *Tetromino) bool {
 	return true
 }
 
+// Apply returns the tetromino moved by the command the way the game moves it, false if it can't move.
+// The tetromino itself stays as it is.
+func (pf *Playfield) Apply(tetro *Tetromino, cmd Command) (*Tetromino, bool) {
+	cand := tetro.Clone()
+	switch cmd {
+	case Rotate:
+		cand.Rotate()
+	case MoveLeft:
+		cand.MoveHoriz(-1)
+	case MoveRight:
+		cand.MoveHoriz(1)
+	case SoftDrop:
+		cand.MoveVert(1)
+	case HardDrop:
+		for pf.CanPlace(cand) {
+			cand.MoveVert(1)
+		}
+		cand.MoveVert(-1)
+		return cand, cand.Points != tetro.Points
+	}
+
+	if !pf.CanPlace(cand) {
+		return tetro, false
+	}
+	return cand, true
+}
+
+// Clone copies the field, e.g. to try out placements on it.
+func (pf *Playfield) Clone() *Playfield {
+	field := make([][]CellKind, len(pf.field))
+	for i, line := range pf.field {
+		field[i] = slices.Clone(line)
+	}
+	return &Playfield{width: pf.width, field: field, emptyLine: pf.emptyLine}
+}
+
 func (pf *Playfield) RemoveCompletedLines() []int {
 	completed := pf.completedLines()
 	for _, k := range completed {
@@ -99,6 +147,37 @@ func (pf *Playfield) RemoveCompletedLines() []int {
 	return completed
 }
 
+// AddGarbage pushes the field up by the lines and fills them with blocks but the hole column.
+// Returns false if blocks are pushed out of the top.
+func (pf *Playfield) AddGarbage(lines, hole int) bool {
+	lines = min(lines, pf.Height())
+	fits := true
+	for i := 1; i <= lines; i++ {
+		if slices.Contains(pf.field[i], CellBlock) {
+			fits = false
+		}
+	}
+
+	for i := 1; i+lines < len(pf.field); i++ { // ignore hidden line
+		copy(pf.field[i], pf.field[i+lines])
+	}
+	for i := len(pf.field) - lines; i < len(pf.field); i++ {
+		fill(pf.field[i], CellBlock)
+		pf.field[i][hole] = CellEmpty
+	}
+	return fits
+}
+
+// IsEmpty reports whether the visible field has no blocks, e.g. after a perfect clear.
+func (pf *Playfield) IsEmpty() bool {
+	for _, line := range pf.field[1:] {
+		if slices.Contains(line, CellBlock) {
+			return false
+		}
+	}
+	return true
+}
+
 func (pf *Playfield) completedLines() []int {
 	completed := make([]int, 0, 4)
 
@@ -126,6 +205,22 @@ func (pf *Playfield) LockDown(tetro *Tetromino) {
 	}
 }
 
+// IsAbove reports whether the whole tetromino is in the hidden line above the visible field.
+func (pf *Playfield) IsAbove(tetro *Tetromino) bool {
+	for _, p := range tetro.Points {
+		if pf.field[p.Y][p.X] != CellHidden {
+			return false
+		}
+	}
+	return true
+}
+
+// Covers reports whether the tetromino is over the visible cell.
+func (pf *Playfield) Covers(tetro *Tetromino, i, j int) bool {
+	return slices.Contains(tetro.Points[:], Point{X: j, Y: i + 1})
+}
+
+// IsHidden reports whether any cell of the tetromino is in the hidden line.
 func (pf *Playfield) IsHidden(tetro *Tetromino) bool {
 	for _, p := range tetro.Points {
 		cell := pf.field[p.Y][p.X]
@@ -137,6 +232,25 @@ func (pf *Playfield) IsHidden(tetro *Tetromino) bool {
 	return false
 }
 
+// String renders the field as text: '#' for blocks, '.' for empty and ' ' for hidden cells.
+func (pf *Playfield) String() string {
+	var sb strings.Builder
+	for _, line := range pf.field {
+		for _, ck := range line {
+			switch ck {
+			case CellBlock:
+				sb.WriteByte('#')
+			case CellEmpty:
+				sb.WriteByte('.')
+			default:
+				sb.WriteByte(' ')
+			}
+		}
+		sb.WriteByte('\n')
+	}
+	return sb.String()
+}
+
 func fill[T any](xs []T, x T) {
 	for i := range len(xs) {
 		xs[i] = x
@@ -0,0 +1,116 @@
+package game
+
+import "math/rand/v2"
+
+// Sim drives a game without a terminal or a clock, one gravity step at a time.
+// Bots, tests and analysis tools use it to play many games fast.
+type Sim struct {
+	gameplay *Gameplay
+	steps    int
+	over     bool
+	reason   GameOverReason
+}
+
+// NewSim starts a game like NewGameplay does, the options are expected to be validated.
+func NewSim(rand func(n int) int, opts Options) *Sim {
+	s := &Sim{gameplay: NewGameplay(rand, opts)}
+	s.gameplay.Preview(1) // the next tetromino is drawn by the steps, Snapshot only reads it
+	return s
+}
+
+// SeededRand makes the tetromino sequence reproducible by the seed.
+func SeededRand(seed uint64) func(n int) int {
+	r := rand.New(rand.NewPCG(seed, seed))
+	return r.IntN
+}
+
+// GarbageRand makes the garbage holes reproducible by the seed. It's another stream than SeededRand,
+// so the tetromino sequence stays the same whatever garbage the game gets.
+func GarbageRand(seed uint64) func(n int) int {
+	r := rand.New(rand.NewPCG(seed, ^seed))
+	return r.IntN
+}
+
+// Step applies the commands to the falling tetromino and then lets the gravity move it once,
+// as if the commands were typed between two ticks. Every command is a key press counted for the finesse.
+// It does nothing once the game is over.
+func (s *Sim) Step(cmds ...Command) []Event {
+	if s.over {
+		return nil
+	}
+
+	for _, cmd := range cmds {
+		s.gameplay.CountInput(cmd)
+		s.gameplay.HandleCommand(cmd)
+	}
+	events := s.gameplay.Update()
+	s.gameplay.Preview(1)
+	s.steps++
+
+	for _, e := range events {
+		if over, ok := e.(GameOverEvent); ok {
+			s.over = true
+			s.reason = over.Reason
+		}
+	}
+	return events
+}
+
+// Run steps the game by the gravity alone until the condition holds or the game is over,
+// and returns the number of steps made. Nil condition runs until the game is over.
+func (s *Sim) Run(until func(Snapshot) bool) int {
+	n := 0
+	for !s.over && (until == nil || !until(s.Snapshot())) {
+		s.Step()
+		n++
+	}
+	return n
+}
+
+// Over reports whether the game is over.
+func (s *Sim) Over() bool {
+	return s.over
+}
+
+// Gameplay gives access to the game for what the snapshot doesn't cover, e.g. the ghost.
+func (s *Sim) Gameplay() *Gameplay {
+	return s.gameplay
+}
+
+// Snapshot is a copy of the game state, it doesn't change as the game goes on.
+type Snapshot struct {
+	Field     [][]CellKind // the visible cells, line by line from the top
+	Tetromino Tetromino
+	Next      Tetromino
+	Score     int
+	Lines     int
+	Level     int
+	Pieces    int
+	Faults    int
+	Steps     int
+	Over      bool
+	Reason    GameOverReason
+}
+
+func (s *Sim) Snapshot() Snapshot {
+	g := s.gameplay
+	field := make([][]CellKind, g.playfield.Height())
+	for i := range field {
+		field[i] = make([]CellKind, g.playfield.Width())
+		g.playfield.CopyLine(i, field[i])
+	}
+
+	return Snapshot{
+		Field:     field,
+		Tetromino: *g.currTetro.Clone(),
+		Next:      *g.queue[0].Clone(),
+		Score:     g.score,
+		Lines:     g.lines,
+		Level:     g.Level(),
+		Pieces:    g.pieces,
+		Faults:    g.faults,
+		Steps:     s.steps,
+		Over:      s.over,
+		Reason:    s.reason,
+	}
+}
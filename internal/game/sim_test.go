@@ -0,0 +1,84 @@
+package game
+
+import "testing"
+
+func TestSimIsReproducibleBySeed(t *testing.T) {
+	play := func() Snapshot {
+		sim := NewSim(SeededRand(42), Options{})
+		for !sim.Over() {
+			sim.Step(MoveLeft, Rotate, HardDrop)
+		}
+		return sim.Snapshot()
+	}
+
+	a, b := play(), play()
+	eq(t, a.Pieces, b.Pieces)
+	eq(t, a.Score, b.Score)
+	eq(t, a.Steps, b.Steps)
+	eq(t, true, a.Over)
+	eq(t, ReasonBlockOut, a.Reason)
+}
+
+func TestSimStepLocksHardDroppedTetromino(t *testing.T) {
+	sim := NewSim(func(n int) int { return 2 }, Options{Width: 4, Height: 4})
+
+	events := sim.Step(HardDrop)
+
+	eq(t, Event(TetroLockedEvent{}), events[0])
+	s := sim.Snapshot()
+	eq(t, 1, s.Pieces)
+	eq(t, 1, s.Steps)
+	eq(t, CellBlock, s.Field[3][1])
+	eq(t, CellEmpty, s.Field[1][1])
+}
+
+func TestSimRunUntilCondition(t *testing.T) {
+	sim := NewSim(SeededRand(7), Options{})
+
+	steps := sim.Run(func(s Snapshot) bool { return s.Pieces == 3 })
+
+	s := sim.Snapshot()
+	eq(t, 3, s.Pieces)
+	eq(t, steps, s.Steps)
+	eq(t, false, s.Over)
+}
+
+func TestSnapshotDoesNotChangeWithGame(t *testing.T) {
+	sim := NewSim(func(n int) int { return 2 }, Options{Width: 4, Height: 4})
+	before := sim.Snapshot()
+
+	sim.Step(HardDrop)
+	sim.Run(nil)
+
+	eq(t, 0, before.Pieces)
+	eq(t, CellEmpty, before.Field[3][1])
+	eq(t, [4]Point{{1, 0}, {2, 0}, {1, 1}, {2, 1}}, before.Tetromino.Points)
+	eq(t, 0, len(sim.Step(HardDrop))) // the game is over
+}
+
+func TestSnapshotLeavesGameAsIs(t *testing.T) {
+	a, b := NewSim(SeededRand(3), Options{}), NewSim(SeededRand(3), Options{})
+	for range 200 {
+		a.Snapshot()
+		eq(t, len(b.gameplay.queue), len(a.gameplay.queue))
+		a.Step(Rotate, MoveRight, HardDrop)
+		b.Step(Rotate, MoveRight, HardDrop)
+	}
+
+	sa, sb := a.Snapshot(), b.Snapshot()
+	eq(t, sb.Pieces, sa.Pieces)
+	eq(t, sb.Score, sa.Score)
+	eq(t, sb.Over, sa.Over)
+	eq(t, sb.Tetromino.Points, sa.Tetromino.Points)
+	eq(t, sb.Next.Points, sa.Next.Points)
+}
+
+func TestSimCountsFinesseFaults(t *testing.T) {
+	sim := NewSim(func(n int) int { return 0 }, Options{})
+
+	sim.Step(MoveLeft, MoveRight, MoveLeft, HardDrop) // one press left would do
+	eq(t, 1, sim.Snapshot().Faults)
+
+	sim.Step(MoveLeft, HardDrop)
+	eq(t, 1, sim.Snapshot().Faults)
+}
@@ -0,0 +1,197 @@
+package keymap
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+
+	"github.com/opennikish/tetris/internal/game"
+	"github.com/opennikish/tetris/internal/terminal"
+)
+
+// Action is what a key does: either a game command or an app level action like quit.
+type Action string
+
+const (
+	MoveLeft  Action = "move-left"
+	MoveRight Action = "move-right"
+	Rotate    Action = "rotate"
+	SoftDrop  Action = "soft-drop"
+	HardDrop  Action = "hard-drop"
+	Options   Action = "options"
+	Quit      Action = "quit"
+)
+
+var actionCommands = map[Action]game.Command{
+	MoveLeft:  game.MoveLeft,
+	MoveRight: game.MoveRight,
+	Rotate:    game.Rotate,
+	SoftDrop:  game.SoftDrop,
+	HardDrop:  game.HardDrop,
+}
+
+// Actions lists every bindable action in the display order.
+var Actions = []Action{MoveLeft, MoveRight, Rotate, SoftDrop, HardDrop, Options, Quit}
+
+// Command returns the game command performed by the action, if any.
+func (a Action) Command() (game.Command, bool) {
+	cmd, ok := actionCommands[a]
+	return cmd, ok
+}
+
+// Bindings maps actions to the keys in the format of terminal.ParseKey.
+type Bindings map[Action][]string
+
+const DefaultPreset = "arrows"
+
+var Presets = map[string]Bindings{
+	"arrows": {
+		MoveLeft:  {"left"},
+		MoveRight: {"right"},
+		Rotate:    {"up"},
+		SoftDrop:  {"down"},
+		HardDrop:  {"space"},
+		Options:   {"o"},
+		Quit:      {"q"},
+	},
+	"wasd": {
+		MoveLeft:  {"a"},
+		MoveRight: {"d"},
+		Rotate:    {"w"},
+		SoftDrop:  {"s"},
+		HardDrop:  {"space"},
+		Options:   {"o"},
+		Quit:      {"q"},
+	},
+	"vim": {
+		MoveLeft:  {"h"},
+		MoveRight: {"l"},
+		Rotate:    {"k"},
+		SoftDrop:  {"j"},
+		HardDrop:  {"space"},
+		Options:   {"o"},
+		Quit:      {"q"},
+	},
+	"left-hand": {
+		MoveLeft:  {"s"},
+		MoveRight: {"f"},
+		Rotate:    {"e"},
+		SoftDrop:  {"d"},
+		HardDrop:  {"space"},
+		Options:   {"o"},
+		Quit:      {"q"},
+	},
+	"right-hand": {
+		MoveLeft:  {"j"},
+		MoveRight: {"l"},
+		Rotate:    {"i"},
+		SoftDrop:  {"k"},
+		HardDrop:  {"space"},
+		Options:   {"o"},
+		Quit:      {"q"},
+	},
+}
+
+// Config is the user config file: a preset and bindings overriding the preset ones action by action.
+type Config struct {
+	Preset   string   `json:"preset"`
+	Bindings Bindings `json:"bindings,omitempty"`
+}
+
+// LoadConfig reads the config from a JSON file, e.g. {"preset": "vim", "bindings": {"rotate": ["k", "up"]}}.
+func LoadConfig(path string) (Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Config{}, fmt.Errorf("read keymap config: %w", err)
+	}
+
+	var cfg Config
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return Config{}, fmt.Errorf("parse keymap config %s: %w", path, err)
+	}
+
+	return cfg, nil
+}
+
+type Keymap struct {
+	actions  map[terminal.Key]Action
+	bindings Bindings
+}
+
+// Default returns the keymap of the default preset.
+func Default() *Keymap {
+	km, err := New(Config{})
+	if err != nil {
+		panic(fmt.Sprintf("default keymap: %s", err))
+	}
+	return km
+}
+
+// New builds the keymap from the config. All problems are reported at once, including keys bound to several actions.
+func New(cfg Config) (*Keymap, error) {
+	preset := cfg.Preset
+	if preset == "" {
+		preset = DefaultPreset
+	}
+
+	base, ok := Presets[preset]
+	if !ok {
+		return nil, fmt.Errorf("unknown preset %q, available: %v", preset, PresetNames())
+	}
+
+	bindings := maps.Clone(base)
+
+	var errs []error
+	for _, a := range slices.Sorted(maps.Keys(cfg.Bindings)) {
+		if !slices.Contains(Actions, a) {
+			errs = append(errs, fmt.Errorf("unknown action %q", a))
+			continue
+		}
+		bindings[a] = cfg.Bindings[a]
+	}
+
+	km := &Keymap{
+		actions:  make(map[terminal.Key]Action),
+		bindings: bindings,
+	}
+	for _, a := range Actions {
+		for _, spec := range bindings[a] {
+			key, err := terminal.ParseKey(spec)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("action %q: %w", a, err))
+				continue
+			}
+
+			if other, ok := km.actions[key]; ok && other != a {
+				errs = append(errs, fmt.Errorf("key %q is bound to both %q and %q", key, other, a))
+				continue
+			}
+			km.actions[key] = a
+		}
+	}
+
+	if err := errors.Join(errs...); err != nil {
+		return nil, fmt.Errorf("invalid key bindings:\n%w", err)
+	}
+
+	return km, nil
+}
+
+// Action resolves the action bound to the key, regardless of whether it's pressed, repeated or released.
+func (km *Keymap) Action(key terminal.Key) (Action, bool) {
+	key.Action = terminal.Press
+	a, ok := km.actions[key]
+	return a, ok
+}
+
+// Keys returns the keys bound to the action.
+func (km *Keymap) Keys(a Action) []string {
+	return km.bindings[a]
+}
+
+func PresetNames() []string {
+	return slices.Sorted(maps.Keys(Presets))
+}
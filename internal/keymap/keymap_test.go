@@ -0,0 +1,79 @@
+package keymap
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/opennikish/tetris/internal/terminal"
+)
+
+func TestPresetsAreValid(t *testing.T) {
+	for _, name := range PresetNames() {
+		km, err := New(Config{Preset: name})
+		if err != nil {
+			t.Fatalf("preset %s: %s", name, err)
+		}
+		for _, a := range Actions {
+			if len(km.Keys(a)) == 0 {
+				t.Errorf("preset %s: no keys for %s", name, a)
+			}
+		}
+	}
+}
+
+func TestOverrideBindings(t *testing.T) {
+	km, err := New(Config{
+		Preset:   "vim",
+		Bindings: Bindings{Rotate: {"k", "up", "ctrl+r"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, key := range []terminal.Key{
+		{Kind: terminal.Letter, Char: 'k'},
+		{Kind: terminal.Up},
+		{Kind: terminal.Letter, Char: 'r', Mod: terminal.ModCtrl},
+	} {
+		a, ok := km.Action(key)
+		eq(t, true, ok)
+		eq(t, Rotate, a)
+	}
+
+	a, _ := km.Action(terminal.Key{Kind: terminal.Letter, Char: 'h'})
+	eq(t, MoveLeft, a)
+
+	_, ok := km.Action(terminal.Key{Kind: terminal.Left})
+	eq(t, false, ok)
+}
+
+func TestReportConflictingBindings(t *testing.T) {
+	_, err := New(Config{
+		Preset: "wasd",
+		Bindings: Bindings{
+			HardDrop: {"s"},
+			Rotate:   {"w", "bogus-key"},
+			"dance":  {"x"},
+		},
+	})
+	if err == nil {
+		t.Fatal("expected error")
+	}
+
+	for _, msg := range []string{
+		`key "s" is bound to both "soft-drop" and "hard-drop"`,
+		`unknown key "bogus-key"`,
+		`unknown action "dance"`,
+	} {
+		if !strings.Contains(err.Error(), msg) {
+			t.Errorf("expected %q in error: %s", msg, err)
+		}
+	}
+}
+
+func eq[T comparable](t *testing.T, expected, actual T) {
+	t.Helper()
+	if expected != actual {
+		t.Fatalf("expected: %v got: %v", expected, actual)
+	}
+}
@@ -0,0 +1,345 @@
+// Package netplay connects two players over TCP. Each side plays its own game and sends every step of it
+// to the other side, which plays the steps on a copy of the opponent's game: the shared seed keeps the copy
+// in sync with the original. The messages are line-delimited JSON, the first one is the hello with the
+// protocol version. Every message carries the time since the connection start of its sender, the host
+// compares the times of the top-outs to tell the winner and sends the result to the joiner.
+//
+// It isn't an input lockstep: neither side waits for the other one's inputs, the game goes on at once and
+// the copy of the opponent's board lags behind by the latency. A tie of top-outs is decided by the times instead.
+package netplay
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/opennikish/tetris/internal/game"
+)
+
+const Version = 2
+
+// Message types.
+const (
+	TypeHello  = "hello"  // the version, and the game offered by the host
+	TypeStep   = "step"   // a gravity tick, a command, a key press or the garbage the sender received
+	TypeAttack = "attack" // garbage lines sent to the receiver
+	TypeOver   = "over"   // the game of the sender topped out at the time of the message
+	TypeResult = "result" // the host tells who lost
+	TypePing   = "ping"
+	TypePong   = "pong" // answers the ping with its time
+	TypeBye    = "bye"  // the sender leaves
+)
+
+const (
+	handshakeTimeout = 5 * time.Second
+	writeTimeout     = 5 * time.Second
+	pingInterval     = time.Second
+)
+
+// Sides of the result.
+const (
+	Host   = "host"
+	Joiner = "joiner"
+)
+
+// Game is what the host offers, the joining side plays the same.
+type Game struct {
+	Seed   uint64 `json:"seed"`
+	Level  int    `json:"level"`
+	Width  int    `json:"width"`
+	Height int    `json:"height"`
+}
+
+// Message is a line of the protocol, the fields used depend on the type.
+type Message struct {
+	Type    string        `json:"type"`
+	Version int           `json:"version,omitempty"`
+	Game    *Game         `json:"game,omitempty"`
+	At      time.Duration `json:"at,omitempty"` // time since the connection start of the sender
+	Gravity bool          `json:"gravity,omitempty"`
+	Command string        `json:"cmd,omitempty"`
+	Press   string        `json:"press,omitempty"` // a key press counted for the finesse
+	Lines   int           `json:"lines,omitempty"`
+	Reason  string        `json:"reason,omitempty"`
+	Loser   string        `json:"loser,omitempty"` // the side that lost, empty for a draw
+}
+
+type Conn struct {
+	conn    net.Conn
+	dec     *json.Decoder
+	mu      sync.Mutex // guards the encoder
+	enc     *json.Encoder
+	game    Game
+	host    bool
+	start   time.Time
+	latency atomic.Int64
+	peerAt  atomic.Int64 // the time of the last message from the other side
+	closed  atomic.Bool
+	err     error
+}
+
+func newConn(conn net.Conn) *Conn {
+	return &Conn{
+		conn:  conn,
+		dec:   json.NewDecoder(conn),
+		enc:   json.NewEncoder(conn),
+		start: time.Now(),
+	}
+}
+
+type Listener struct {
+	ln net.Listener
+}
+
+// Listen waits for the opponent on the address, e.g. ":7777" or "127.0.0.1:0" for a random port.
+func Listen(addr string) (*Listener, error) {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("listen: %w", err)
+	}
+	return &Listener{ln: ln}, nil
+}
+
+func (l *Listener) Addr() net.Addr {
+	return l.ln.Addr()
+}
+
+func (l *Listener) Close() error {
+	return l.ln.Close()
+}
+
+// Accept waits for the opponent to join and offers the game.
+func (l *Listener) Accept(ctx context.Context, g Game) (*Conn, error) {
+	stop := context.AfterFunc(ctx, func() { l.ln.Close() })
+	conn, err := l.ln.Accept()
+	stop()
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, fmt.Errorf("accept: %w", err)
+	}
+
+	c := newConn(conn)
+	c.game = g
+	c.host = true
+	conn.SetDeadline(time.Now().Add(handshakeTimeout))
+	if err := c.send(Message{Type: TypeHello, Version: Version, Game: &g}); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if _, err := c.readHello(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	conn.SetDeadline(time.Time{})
+	return c, nil
+}
+
+// Dial joins the host and takes the game it offers.
+func Dial(ctx context.Context, addr string) (*Conn, error) {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("dial: %w", err)
+	}
+
+	c := newConn(conn)
+	conn.SetDeadline(time.Now().Add(handshakeTimeout))
+	hello, err := c.readHello()
+	if err == nil && hello.Game == nil {
+		err = errors.New("handshake: the host offered no game")
+	}
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	c.game = *hello.Game
+	if err := c.send(Message{Type: TypeHello, Version: Version}); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	conn.SetDeadline(time.Time{})
+	return c, nil
+}
+
+// readHello reads the hello of the other side, it answers a hello of another version with its own
+// so that both sides report the mismatch.
+func (c *Conn) readHello() (Message, error) {
+	var m Message
+	if err := c.dec.Decode(&m); err != nil {
+		return Message{}, fmt.Errorf("handshake: %w", err)
+	}
+	if m.Type != TypeHello {
+		return Message{}, fmt.Errorf("handshake: expected hello, got %q", m.Type)
+	}
+	if m.Version != Version {
+		c.send(Message{Type: TypeHello, Version: Version})
+		return Message{}, fmt.Errorf("handshake: the opponent speaks protocol version %d, want %d", m.Version, Version)
+	}
+	return m, nil
+}
+
+// Game returns the game both sides play.
+func (c *Conn) Game() Game {
+	return c.game
+}
+
+// IsHost tells whether the connection was accepted, the host decides the result.
+func (c *Conn) IsHost() bool {
+	return c.host
+}
+
+// Elapsed returns the time since the connection start, the time of the messages.
+func (c *Conn) Elapsed() time.Duration {
+	return time.Since(c.start)
+}
+
+// PeerAt returns the time of the last message the other side sent: its game was still going on then
+// unless the message said otherwise.
+func (c *Conn) PeerAt() time.Duration {
+	return time.Duration(c.peerAt.Load())
+}
+
+// Gravity sends a gravity tick of the own game.
+func (c *Conn) Gravity() error {
+	return c.send(Message{Type: TypeStep, Gravity: true})
+}
+
+// Command sends a command of the own game.
+func (c *Conn) Command(cmd game.Command) error {
+	return c.send(Message{Type: TypeStep, Command: cmd.String()})
+}
+
+// Press sends a key press of the own game, see game.Gameplay.CountInput.
+func (c *Conn) Press(cmd game.Command) error {
+	return c.send(Message{Type: TypeStep, Press: cmd.String()})
+}
+
+// Garbage tells that the own game queued the garbage lines at this point.
+func (c *Conn) Garbage(lines int) error {
+	return c.send(Message{Type: TypeStep, Lines: lines})
+}
+
+// Attack sends the garbage lines to the opponent.
+func (c *Conn) Attack(lines int) error {
+	return c.send(Message{Type: TypeAttack, Lines: lines})
+}
+
+// Over tells that the own game topped out at the time, see Elapsed.
+func (c *Conn) Over(at time.Duration, reason game.GameOverReason) error {
+	return c.send(Message{Type: TypeOver, At: at, Reason: string(reason)})
+}
+
+// Result tells the joiner who lost, Host, Joiner or empty for a draw.
+func (c *Conn) Result(loser string, reason game.GameOverReason) error {
+	return c.send(Message{Type: TypeResult, Loser: loser, Reason: string(reason)})
+}
+
+// Latency returns the last measured round trip time.
+func (c *Conn) Latency() time.Duration {
+	return time.Duration(c.latency.Load())
+}
+
+func (c *Conn) send(m Message) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if m.At == 0 {
+		m.At = time.Since(c.start)
+	}
+	c.conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+	if err := c.enc.Encode(m); err != nil {
+		return fmt.Errorf("send %s: %w", m.Type, err)
+	}
+	return nil
+}
+
+// Receive reads the messages of the opponent and pings it every second. The pings are answered here and
+// the pongs come after updating the latency. The channel is closed after a bye or when the connection
+// ends, Err tells why.
+func (c *Conn) Receive(ctx context.Context) <-chan Message {
+	out := make(chan Message)
+	ctx, cancel := context.WithCancel(ctx)
+
+	go func() {
+		t := time.NewTicker(pingInterval)
+		defer t.Stop()
+		for {
+			if err := c.send(Message{Type: TypePing}); err != nil {
+				return
+			}
+			select {
+			case <-t.C:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	go func() {
+		defer close(out)
+		defer cancel()
+		stop := context.AfterFunc(ctx, func() { c.conn.SetReadDeadline(time.Now()) })
+		defer stop()
+
+		for {
+			var m Message
+			if err := c.dec.Decode(&m); err != nil {
+				switch {
+				case ctx.Err() != nil || c.closed.Load():
+				case errors.Is(err, io.EOF):
+					c.err = errors.New("the opponent has disconnected")
+				default:
+					c.err = fmt.Errorf("receive: %w", err)
+				}
+				return
+			}
+
+			if m.Type != TypePong {
+				c.peerAt.Store(int64(m.At)) // the pong carries the own time
+			}
+			switch m.Type {
+			case TypePing:
+				if err := c.send(Message{Type: TypePong, At: m.At}); err != nil {
+					c.err = err
+					return
+				}
+				continue
+			case TypePong:
+				c.latency.Store(int64(time.Since(c.start) - m.At))
+			}
+
+			select {
+			case out <- m:
+			case <-ctx.Done():
+				return
+			}
+			if m.Type == TypeBye {
+				return
+			}
+		}
+	}()
+
+	return out
+}
+
+// Err returns why the connection ended, nil after a bye, it's valid once the Receive channel is closed.
+func (c *Conn) Err() error {
+	return c.err
+}
+
+// Close says bye to the opponent and closes the connection.
+func (c *Conn) Close() error {
+	if c.closed.Swap(true) {
+		return nil
+	}
+	c.send(Message{Type: TypeBye, Reason: "quit"})
+	return c.conn.Close()
+}
@@ -0,0 +1,186 @@
+package netplay
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/opennikish/tetris/internal/game"
+)
+
+func connect(t *testing.T, g Game) (host, joiner *Conn) {
+	t.Helper()
+	ln, err := Listen("127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	accepted := make(chan error)
+	go func() {
+		var err error
+		host, err = ln.Accept(context.Background(), g)
+		accepted <- err
+	}()
+
+	joiner, err = Dial(context.Background(), ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := <-accepted; err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		host.Close()
+		joiner.Close()
+	})
+	return host, joiner
+}
+
+// next returns the next message but the pongs.
+func next(t *testing.T, msgs <-chan Message) (Message, bool) {
+	t.Helper()
+	for {
+		select {
+		case m, ok := <-msgs:
+			if ok && m.Type == TypePong {
+				continue
+			}
+			m.At = 0
+			return m, ok
+		case <-time.After(time.Second):
+			t.Fatal("no message")
+		}
+	}
+}
+
+func TestJoinerGetsHostGame(t *testing.T) {
+	g := Game{Seed: 42, Level: 3, Width: 8, Height: 16}
+	host, joiner := connect(t, g)
+	eq(t, g, host.Game())
+	eq(t, g, joiner.Game())
+}
+
+func TestStepsAttacksAndBye(t *testing.T) {
+	host, joiner := connect(t, Game{Seed: 1})
+	msgs := joiner.Receive(context.Background())
+
+	host.Gravity()
+	host.Press(game.MoveLeft)
+	host.Command(game.MoveLeft)
+	host.Garbage(2)
+	host.Attack(3)
+	host.Close()
+
+	expected := []Message{
+		{Type: TypeStep, Gravity: true},
+		{Type: TypeStep, Press: "move-left"},
+		{Type: TypeStep, Command: "move-left"},
+		{Type: TypeStep, Lines: 2},
+		{Type: TypeAttack, Lines: 3},
+		{Type: TypeBye, Reason: "quit"},
+	}
+	for _, e := range expected {
+		m, ok := next(t, msgs)
+		eq(t, true, ok)
+		eq(t, e, m)
+	}
+	_, ok := next(t, msgs)
+	eq(t, false, ok)
+	eq(t, nil, joiner.Err())
+}
+
+func TestOverKeepsItsTimeAndHostSendsResult(t *testing.T) {
+	host, joiner := connect(t, Game{Seed: 1})
+	eq(t, true, host.IsHost())
+	eq(t, false, joiner.IsHost())
+	msgs := host.Receive(context.Background())
+
+	joiner.Over(3*time.Millisecond, game.ReasonBlockOut)
+	m, _ := next(t, msgs)
+	eq(t, Message{Type: TypeOver, Reason: "block out"}, m)
+	eq(t, 3*time.Millisecond, host.PeerAt())
+
+	results := joiner.Receive(context.Background())
+	host.Result(Joiner, game.ReasonBlockOut)
+	m, ok := next(t, results)
+	eq(t, true, ok)
+	eq(t, Message{Type: TypeResult, Loser: Joiner, Reason: "block out"}, m)
+}
+
+func TestPongMeasuresLatency(t *testing.T) {
+	host, joiner := connect(t, Game{Seed: 1})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	host.Receive(ctx) // answers the pings
+	msgs := joiner.Receive(ctx)
+
+	select {
+	case m := <-msgs:
+		eq(t, TypePong, m.Type)
+	case <-time.After(time.Second):
+		t.Fatal("no pong")
+	}
+	if joiner.Latency() <= 0 {
+		t.Fatalf("expected positive latency, got %s", joiner.Latency())
+	}
+}
+
+func TestDisconnectWithoutBye(t *testing.T) {
+	host, joiner := connect(t, Game{Seed: 1})
+	msgs := host.Receive(context.Background())
+
+	joiner.conn.Close()
+
+	_, ok := next(t, msgs)
+	eq(t, false, ok)
+	eq(t, "the opponent has disconnected", host.Err().Error())
+}
+
+func TestHandshakeRejectsOtherVersion(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		json.NewEncoder(conn).Encode(Message{Type: TypeHello, Version: Version + 1, Game: &Game{Seed: 1}})
+
+		var reply Message
+		json.NewDecoder(conn).Decode(&reply)
+	}()
+
+	_, err = Dial(context.Background(), ln.Addr().String())
+	if err == nil {
+		t.Fatal("expected error")
+	}
+	eq(t, "handshake: the opponent speaks protocol version 3, want 2", err.Error())
+}
+
+func TestAcceptStopsWithContext(t *testing.T) {
+	ln, err := Listen("127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = ln.Accept(ctx, Game{})
+	eq(t, context.Canceled, err)
+}
+
+func eq[T comparable](t *testing.T, expected, actual T) {
+	t.Helper()
+	if expected != actual {
+		t.Fatalf("expected: %v got: %v", expected, actual)
+	}
+}
@@ -0,0 +1,146 @@
+// Package replay records games as line-delimited JSON and plays them back.
+// The first line is the header with everything needed to recreate the game, the rest are steps.
+package replay
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/opennikish/tetris/internal/game"
+)
+
+const Version = 2 // adds the key presses, version 1 recordings play without them
+
+type Header struct {
+	Version int    `json:"version"`
+	Seed    uint64 `json:"seed"`
+	Mode    string `json:"mode"`
+	Level   int    `json:"level"`
+	Width   int    `json:"width"`
+	Height  int    `json:"height"`
+}
+
+// Step is either a gravity tick, a player command or a key press counted for the finesse,
+// happened at the given time since the start.
+type Step struct {
+	At      time.Duration `json:"at"`
+	Gravity bool          `json:"gravity,omitempty"`
+	Command string        `json:"cmd,omitempty"`
+	Press   string        `json:"press,omitempty"`
+}
+
+type Recorder struct {
+	enc   *json.Encoder
+	now   func() time.Time
+	start time.Time
+}
+
+// NewRecorder writes the header right away, the steps are written as they happen.
+func NewRecorder(w io.Writer, h Header, now func() time.Time) (*Recorder, error) {
+	h.Version = Version
+	enc := json.NewEncoder(w)
+	if err := enc.Encode(h); err != nil {
+		return nil, fmt.Errorf("write replay header: %w", err)
+	}
+
+	return &Recorder{
+		enc:   enc,
+		now:   now,
+		start: now(),
+	}, nil
+}
+
+func (r *Recorder) Gravity() error {
+	return r.write(Step{Gravity: true})
+}
+
+func (r *Recorder) Command(cmd game.Command) error {
+	return r.write(Step{Command: cmd.String()})
+}
+
+// Press records the key press of the command, see game.Gameplay.CountInput.
+func (r *Recorder) Press(cmd game.Command) error {
+	return r.write(Step{Press: cmd.String()})
+}
+
+func (r *Recorder) write(s Step) error {
+	s.At = r.now().Sub(r.start)
+	if err := r.enc.Encode(s); err != nil {
+		return fmt.Errorf("write replay step: %w", err)
+	}
+	return nil
+}
+
+// Load reads a whole recording and checks that every step can be played back.
+func Load(r io.Reader) (Header, []Step, error) {
+	sc := bufio.NewScanner(r)
+	if !sc.Scan() {
+		if err := sc.Err(); err != nil {
+			return Header{}, nil, fmt.Errorf("read replay: %w", err)
+		}
+		return Header{}, nil, errors.New("read replay: empty file")
+	}
+
+	var h Header
+	if err := json.Unmarshal(sc.Bytes(), &h); err != nil {
+		return Header{}, nil, fmt.Errorf("parse replay header: %w", err)
+	}
+	if h.Version < 1 || h.Version > Version {
+		return Header{}, nil, fmt.Errorf("unsupported replay version %d, want %d", h.Version, Version)
+	}
+
+	var steps []Step
+	for line := 2; sc.Scan(); line++ {
+		var s Step
+		if err := json.Unmarshal(sc.Bytes(), &s); err != nil {
+			return Header{}, nil, fmt.Errorf("parse replay line %d: %w", line, err)
+		}
+		name := s.Command
+		if s.Press != "" {
+			name = s.Press
+		}
+		if _, ok := game.ParseCommand(name); !s.Gravity && !ok {
+			return Header{}, nil, fmt.Errorf("parse replay line %d: unknown command %q", line, name)
+		}
+		steps = append(steps, s)
+	}
+	if err := sc.Err(); err != nil {
+		return Header{}, nil, fmt.Errorf("read replay: %w", err)
+	}
+
+	return h, steps, nil
+}
+
+// Play sends the steps at their recorded time divided by the speed, the channel is closed after the last one.
+func Play(ctx context.Context, steps []Step, speed float64) <-chan Step {
+	out := make(chan Step)
+
+	go func() {
+		defer close(out)
+
+		start := time.Now()
+		for _, s := range steps {
+			due := time.Duration(float64(s.At) / speed)
+			if wait := due - time.Since(start); wait > 0 {
+				select {
+				case <-time.After(wait):
+				case <-ctx.Done():
+					return
+				}
+			}
+
+			select {
+			case out <- s:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return out
+}
@@ -0,0 +1,93 @@
+package replay
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/opennikish/tetris/internal/game"
+)
+
+func TestRecordAndLoad(t *testing.T) {
+	var buf bytes.Buffer
+	clock := time.Unix(0, 0)
+	now := func() time.Time { return clock }
+
+	rec, err := NewRecorder(&buf, Header{Seed: 42, Mode: "marathon", Level: 2, Width: 10, Height: 20}, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	clock = clock.Add(100 * time.Millisecond)
+	rec.Press(game.MoveLeft)
+	rec.Command(game.MoveLeft)
+	clock = clock.Add(400 * time.Millisecond)
+	rec.Gravity()
+
+	h, steps, err := Load(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	eq(t, Header{Version: Version, Seed: 42, Mode: "marathon", Level: 2, Width: 10, Height: 20}, h)
+	eq(t, 3, len(steps))
+	eq(t, Step{At: 100 * time.Millisecond, Press: "move-left"}, steps[0])
+	eq(t, Step{At: 100 * time.Millisecond, Command: "move-left"}, steps[1])
+	eq(t, Step{At: 500 * time.Millisecond, Gravity: true}, steps[2])
+}
+
+func TestLoadRejectsBrokenFiles(t *testing.T) {
+	cases := map[string]string{
+		"":                                     "read replay: empty file",
+		`{"version":9}`:                        "unsupported replay version 9, want 2",
+		"{\"version\":1}\n{\"cmd\":\"fly\"}":   `parse replay line 2: unknown command "fly"`,
+		"{\"version\":2}\n{\"press\":\"fly\"}": `parse replay line 2: unknown command "fly"`,
+		"{\"version\":1}\nnot json":            "parse replay line 2: invalid character 'o' in literal null (expecting 'u')",
+	}
+
+	for input, expected := range cases {
+		_, _, err := Load(strings.NewReader(input))
+		if err == nil {
+			t.Fatalf("expected error for %q", input)
+		}
+		eq(t, expected, err.Error())
+	}
+}
+
+func TestPlaySendsStepsInOrder(t *testing.T) {
+	steps := []Step{
+		{At: 0, Gravity: true},
+		{At: 10 * time.Millisecond, Command: "rotate"},
+		{At: 20 * time.Millisecond, Gravity: true},
+	}
+
+	var played []Step
+	for s := range Play(context.Background(), steps, 10) {
+		played = append(played, s)
+	}
+
+	eq(t, len(steps), len(played))
+	for i := range steps {
+		eq(t, steps[i], played[i])
+	}
+}
+
+func TestPlayStopsOnCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	out := Play(ctx, []Step{{At: time.Hour, Gravity: true}}, 1)
+	cancel()
+
+	select {
+	case _, ok := <-out:
+		eq(t, false, ok)
+	case <-time.After(time.Second):
+		t.Fatal("player didn't stop")
+	}
+}
+
+func eq[T comparable](t *testing.T, expected, actual T) {
+	t.Helper()
+	if expected != actual {
+		t.Fatalf("expected: %v got: %v", expected, actual)
+	}
+}
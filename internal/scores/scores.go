@@ -0,0 +1,227 @@
+// Package scores keeps the high-score tables of every game mode in a JSON file.
+package scores
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"time"
+)
+
+const (
+	FileName     = "scores.json"
+	DefaultLimit = 10
+	version      = 1
+)
+
+type Entry struct {
+	Name     string        `json:"name"`
+	Score    int           `json:"score"`
+	Lines    int           `json:"lines"`
+	Level    int           `json:"level"`
+	Duration time.Duration `json:"duration"`
+	Date     time.Time     `json:"date"`
+}
+
+// Order tells which entries are better.
+type Order int
+
+const (
+	ByScore Order = iota // higher score, for endless modes
+	ByTime               // shorter duration, for modes with a goal
+)
+
+func (o Order) better(a, b Entry) bool {
+	if o == ByTime && a.Duration != b.Duration {
+		return a.Duration < b.Duration
+	}
+	if a.Score != b.Score {
+		return a.Score > b.Score
+	}
+	if a.Lines != b.Lines {
+		return a.Lines > b.Lines
+	}
+	return a.Date.Before(b.Date) // the first one to make it keeps the place
+}
+
+// Tables are the entries of each mode, best first.
+type Tables map[string][]Entry
+
+type file struct {
+	Version int    `json:"version"`
+	Modes   Tables `json:"modes"`
+}
+
+type Store struct {
+	path  string
+	limit int
+}
+
+func NewStore(path string, limit int) *Store {
+	return &Store{path: path, limit: limit}
+}
+
+// Dir returns $XDG_DATA_HOME/tetris, falling back to ~/.local/share/tetris.
+func Dir(getenv func(key string) string) (string, error) {
+	if dir := getenv("XDG_DATA_HOME"); filepath.IsAbs(dir) {
+		return filepath.Join(dir, "tetris"), nil
+	}
+
+	if runtime.GOOS == "windows" {
+		dir, err := os.UserConfigDir()
+		if err != nil {
+			return "", fmt.Errorf("locate data dir: %w", err)
+		}
+		return filepath.Join(dir, "tetris"), nil
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("locate data dir: %w", err)
+	}
+	return filepath.Join(home, ".local", "share", "tetris"), nil
+}
+
+// Load reads all the tables, there are none until the first score is added.
+func (s *Store) Load() (Tables, error) {
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return Tables{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read scores: %w", err)
+	}
+
+	var f file
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("parse scores %s: %w", s.path, err)
+	}
+	if f.Version != version {
+		return nil, fmt.Errorf("parse scores %s: unsupported version %d", s.path, f.Version)
+	}
+	if f.Modes == nil {
+		f.Modes = Tables{}
+	}
+	return f.Modes, nil
+}
+
+// Rank returns the place (1-based) the entry would take in the mode table, false if it doesn't make the table.
+func (s *Store) Rank(mode string, order Order, e Entry) (int, bool, error) {
+	tables, err := s.Load()
+	if err != nil {
+		return 0, false, err
+	}
+
+	rank := place(tables[mode], order, e)
+	return rank + 1, rank < s.limit, nil
+}
+
+// Add puts the entry into the mode table and returns its place, 0 if it didn't make the table.
+// The file is locked for the update, so several players can share it.
+func (s *Store) Add(mode string, order Order, e Entry) (int, error) {
+	unlock, err := lock(s.path)
+	if err != nil {
+		return 0, err
+	}
+	defer unlock()
+
+	tables, err := s.Load()
+	if err != nil {
+		return 0, err
+	}
+
+	table := tables[mode]
+	rank := place(table, order, e)
+	if rank >= s.limit {
+		return 0, nil
+	}
+	table = slices.Insert(table, rank, e)
+	tables[mode] = table[:min(len(table), s.limit)]
+
+	if err := s.save(tables); err != nil {
+		return 0, err
+	}
+	return rank + 1, nil
+}
+
+func place(table []Entry, order Order, e Entry) int {
+	for i, other := range table {
+		if order.better(e, other) {
+			return i
+		}
+	}
+	return len(table)
+}
+
+// save writes the file atomically, so a crash never leaves a half written table.
+func (s *Store) save(tables Tables) error {
+	data, err := json.MarshalIndent(file{Version: version, Modes: tables}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encode scores: %w", err)
+	}
+
+	dir := filepath.Dir(s.path)
+	tmp, err := os.CreateTemp(dir, FileName+".*")
+	if err != nil {
+		return fmt.Errorf("save scores: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(append(data, '\n')); err != nil {
+		tmp.Close()
+		return fmt.Errorf("save scores: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("save scores: %w", err)
+	}
+	// temp files are private, the table is meant to be shared
+	if err := os.Chmod(tmp.Name(), 0o644); err != nil {
+		return fmt.Errorf("save scores: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), s.path); err != nil {
+		return fmt.Errorf("save scores: %w", err)
+	}
+
+	return nil
+}
+
+// Lock file timings: a lock older than staleLock is left by a crashed process.
+const (
+	lockRetry   = 10 * time.Millisecond
+	lockTimeout = 2 * time.Second
+	staleLock   = 10 * time.Second
+)
+
+// lock takes the lock file next to the path, it works the same on every OS and file system.
+func lock(path string) (func(), error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("create scores dir: %w", err)
+	}
+
+	name := path + ".lock"
+	deadline := time.Now().Add(lockTimeout)
+	for {
+		f, err := os.OpenFile(name, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o644)
+		if err == nil {
+			f.Close()
+			return func() { os.Remove(name) }, nil
+		}
+		if !errors.Is(err, fs.ErrExist) {
+			return nil, fmt.Errorf("lock scores: %w", err)
+		}
+
+		if info, err := os.Stat(name); err == nil && time.Since(info.ModTime()) > staleLock {
+			os.Remove(name)
+			continue
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("lock scores: %s is held by another process", name)
+		}
+		time.Sleep(lockRetry)
+	}
+}
@@ -0,0 +1,125 @@
+package scores
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestAddKeepsTopEntries(t *testing.T) {
+	store := NewStore(filepath.Join(t.TempDir(), "tetris", FileName), 3)
+
+	for i, score := range []int{100, 400, 200, 300} {
+		_, err := store.Add("marathon", ByScore, Entry{Name: "p", Score: score, Date: time.Unix(int64(i), 0)})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	rank, err := store.Add("marathon", ByScore, Entry{Name: "late", Score: 50})
+	if err != nil {
+		t.Fatal(err)
+	}
+	eq(t, 0, rank)
+
+	tables, err := store.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	table := tables["marathon"]
+	eq(t, 3, len(table))
+	eq(t, 400, table[0].Score)
+	eq(t, 300, table[1].Score)
+	eq(t, 200, table[2].Score)
+}
+
+func TestTiedEntryGoesAfterEarlierOne(t *testing.T) {
+	store := NewStore(filepath.Join(t.TempDir(), FileName), DefaultLimit)
+
+	store.Add("marathon", ByScore, Entry{Name: "first", Score: 100, Date: time.Unix(1, 0)})
+	rank, _ := store.Add("marathon", ByScore, Entry{Name: "second", Score: 100, Date: time.Unix(2, 0)})
+
+	eq(t, 2, rank)
+}
+
+func TestByTimeRanksFasterFirst(t *testing.T) {
+	store := NewStore(filepath.Join(t.TempDir(), FileName), DefaultLimit)
+
+	store.Add("sprint", ByTime, Entry{Name: "slow", Duration: 90 * time.Second})
+	rank, _, err := store.Rank("sprint", ByTime, Entry{Name: "fast", Duration: 60 * time.Second})
+	if err != nil {
+		t.Fatal(err)
+	}
+	eq(t, 1, rank)
+
+	tables, _ := store.Load()
+	eq(t, 0, len(tables["marathon"]))
+}
+
+func TestRankOutsideTheTable(t *testing.T) {
+	store := NewStore(filepath.Join(t.TempDir(), FileName), 1)
+	store.Add("marathon", ByScore, Entry{Score: 100})
+
+	rank, ok, err := store.Rank("marathon", ByScore, Entry{Score: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	eq(t, 2, rank)
+	eq(t, false, ok)
+}
+
+func TestConcurrentAddsKeepEveryEntry(t *testing.T) {
+	path := filepath.Join(t.TempDir(), FileName)
+
+	var wg sync.WaitGroup
+	for i := range 5 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := NewStore(path, DefaultLimit).Add("marathon", ByScore, Entry{Score: i}); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	tables, err := NewStore(path, DefaultLimit).Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	eq(t, 5, len(tables["marathon"]))
+
+	entries, _ := os.ReadDir(filepath.Dir(path))
+	eq(t, 1, len(entries)) // neither temp nor lock files are left behind
+}
+
+func TestLoadRejectsUnknownVersion(t *testing.T) {
+	path := filepath.Join(t.TempDir(), FileName)
+	os.WriteFile(path, []byte(`{"version": 7}`), 0o644)
+
+	_, err := NewStore(path, DefaultLimit).Load()
+	if err == nil {
+		t.Fatal("expected error")
+	}
+}
+
+func TestDirHonoursXDG(t *testing.T) {
+	dir, err := Dir(func(key string) string {
+		if key == "XDG_DATA_HOME" {
+			return "/data"
+		}
+		return ""
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	eq(t, filepath.Join("/data", "tetris"), dir)
+}
+
+func eq[T comparable](t *testing.T, expected, actual T) {
+	t.Helper()
+	if expected != actual {
+		t.Fatalf("expected: %v got: %v", expected, actual)
+	}
+}
@@ -0,0 +1,97 @@
+package settings
+
+import (
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/opennikish/tetris/internal/keymap"
+	"github.com/opennikish/tetris/internal/tui"
+)
+
+// Option is a line of the options screen: the current value and how to step it up or down.
+type Option struct {
+	Label  string
+	Value  func(s Settings) string
+	Change func(s *Settings, step int) // step is +1 or -1, the result stays valid
+}
+
+var Options = []Option{
+	{
+		Label: "Gravity",
+		Value: func(s Settings) string { return s.Gravity.String() },
+		Change: func(s *Settings, step int) {
+			stepDuration(&s.Gravity, step, 50*time.Millisecond, MinGravity, MaxGravity)
+		},
+	},
+	{
+		Label:  "DAS",
+		Value:  func(s Settings) string { return s.DAS.String() },
+		Change: func(s *Settings, step int) { stepDuration(&s.DAS, step, 10*time.Millisecond, 0, MaxDAS) },
+	},
+	{
+		Label:  "ARR",
+		Value:  func(s Settings) string { return s.ARR.String() },
+		Change: func(s *Settings, step int) { stepDuration(&s.ARR, step, 5*time.Millisecond, 0, MaxARR) },
+	},
+	{
+		Label:  "Soft drop",
+		Value:  func(s Settings) string { return strconv.Itoa(s.SoftDropFactor) + "x" },
+		Change: func(s *Settings, step int) { s.SoftDropFactor = clamp(s.SoftDropFactor+step, 1, MaxFactor) },
+	},
+	{
+		Label: "Keys",
+		Value: func(s Settings) string { return s.Keys.Preset },
+		Change: func(s *Settings, step int) {
+			// custom bindings are kept, unless they conflict with the new preset
+			cfg := s.Keys
+			cfg.Preset = cycle(keymap.PresetNames(), cfg.Preset, step)
+			if _, err := keymap.New(cfg); err != nil {
+				cfg.Bindings = nil
+			}
+			s.Keys = cfg
+		},
+	},
+	{
+		Label:  "Theme",
+		Value:  func(s Settings) string { return s.Theme },
+		Change: func(s *Settings, step int) { s.Theme = cycle(tui.ThemeNames(), s.Theme, step) },
+	},
+	{
+		Label:  "Colors",
+		Value:  func(s Settings) string { return string(s.ColorMode) },
+		Change: func(s *Settings, step int) { s.ColorMode = cycle(tui.ColorModes, s.ColorMode, step) },
+	},
+	{
+		Label:  "Preview",
+		Value:  func(s Settings) string { return strconv.Itoa(s.Preview) },
+		Change: func(s *Settings, step int) { s.Preview = clamp(s.Preview+step, 0, MaxPreview) },
+	},
+	{
+		Label: "Ghost",
+		Value: func(s Settings) string {
+			if s.Ghost {
+				return "on"
+			}
+			return "off"
+		},
+		Change: func(s *Settings, step int) { s.Ghost = !s.Ghost },
+	},
+}
+
+func stepDuration(d *Duration, step int, by, lo, hi time.Duration) {
+	*d = Duration(clamp(time.Duration(*d)+time.Duration(step)*by, lo, hi))
+}
+
+func clamp[T int | time.Duration](v, lo, hi T) T {
+	return min(max(v, lo), hi)
+}
+
+// cycle returns the value next to the current one, wrapping around. Unknown current value starts from the first.
+func cycle[T comparable](values []T, current T, step int) T {
+	i := slices.Index(values, current)
+	if i < 0 {
+		return values[0]
+	}
+	return values[(i+step+len(values))%len(values)]
+}
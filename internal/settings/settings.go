@@ -0,0 +1,196 @@
+// Package settings keeps the user preferences in a JSON file under the XDG config dir.
+package settings
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/opennikish/tetris/internal/autorepeat"
+	"github.com/opennikish/tetris/internal/keymap"
+	"github.com/opennikish/tetris/internal/tui"
+)
+
+const FileName = "settings.json"
+
+// Limits of the values, the options screen keeps the values within them.
+const (
+	MinGravity = 50 * time.Millisecond
+	MaxGravity = 2 * time.Second
+	MaxDAS     = 500 * time.Millisecond
+	MaxARR     = 200 * time.Millisecond
+	MaxFactor  = 40
+	MaxPreview = 5
+)
+
+type Settings struct {
+	Gravity        Duration      `json:"gravity"` // gravity interval at level 1
+	DAS            Duration      `json:"das"`
+	ARR            Duration      `json:"arr"`
+	SoftDropFactor int           `json:"soft_drop_factor"`
+	Keys           keymap.Config `json:"keys"`
+	Theme          string        `json:"theme"`
+	ColorMode      tui.ColorMode `json:"color_mode"`
+	Preview        int           `json:"preview"` // how many next tetrominoes to show
+	Ghost          bool          `json:"ghost"`
+}
+
+func Default() Settings {
+	repeat := autorepeat.DefaultConfig()
+	return Settings{
+		Gravity:        Duration(500 * time.Millisecond),
+		DAS:            Duration(repeat.DAS),
+		ARR:            Duration(repeat.ARR),
+		SoftDropFactor: repeat.SoftDropFactor,
+		Keys:           keymap.Config{Preset: keymap.DefaultPreset},
+		Theme:          tui.DefaultTheme,
+		ColorMode:      tui.ColorAuto,
+	}
+}
+
+// Repeat returns the auto-repeat part of the settings.
+func (s Settings) Repeat() autorepeat.Config {
+	return autorepeat.Config{
+		DAS:            time.Duration(s.DAS),
+		ARR:            time.Duration(s.ARR),
+		SoftDropFactor: s.SoftDropFactor,
+	}
+}
+
+// Validate reports all problems at once, naming the fields as they are in the file.
+func (s Settings) Validate() error {
+	var errs []error
+	if d := time.Duration(s.Gravity); d < MinGravity || d > MaxGravity {
+		errs = append(errs, fmt.Errorf("gravity must be in range %s..%s, got %s", MinGravity, MaxGravity, d))
+	}
+	if d := time.Duration(s.DAS); d < 0 || d > MaxDAS {
+		errs = append(errs, fmt.Errorf("das must be in range 0s..%s, got %s", MaxDAS, d))
+	}
+	if d := time.Duration(s.ARR); d < 0 || d > MaxARR {
+		errs = append(errs, fmt.Errorf("arr must be in range 0s..%s, got %s", MaxARR, d))
+	}
+	if s.SoftDropFactor < 1 || s.SoftDropFactor > MaxFactor {
+		errs = append(errs, fmt.Errorf("soft_drop_factor must be in range 1..%d, got %d", MaxFactor, s.SoftDropFactor))
+	}
+	if _, err := keymap.New(s.Keys); err != nil {
+		errs = append(errs, fmt.Errorf("keys: %w", err))
+	}
+	if _, ok := tui.Themes[s.Theme]; !ok {
+		errs = append(errs, fmt.Errorf("theme must be one of %s, got %q", strings.Join(tui.ThemeNames(), ", "), s.Theme))
+	}
+	if !slices.Contains(tui.ColorModes, s.ColorMode) {
+		errs = append(errs, fmt.Errorf("color_mode must be one of %s, got %q", joinModes(), s.ColorMode))
+	}
+	if s.Preview < 0 || s.Preview > MaxPreview {
+		errs = append(errs, fmt.Errorf("preview must be in range 0..%d, got %d", MaxPreview, s.Preview))
+	}
+
+	if err := errors.Join(errs...); err != nil {
+		return fmt.Errorf("invalid settings:\n%w", err)
+	}
+	return nil
+}
+
+func joinModes() string {
+	names := make([]string, len(tui.ColorModes))
+	for i, m := range tui.ColorModes {
+		names[i] = string(m)
+	}
+	return strings.Join(names, ", ")
+}
+
+// Dir returns $XDG_CONFIG_HOME/tetris, falling back to the OS config dir, e.g. ~/.config/tetris.
+func Dir(getenv func(key string) string) (string, error) {
+	if dir := getenv("XDG_CONFIG_HOME"); filepath.IsAbs(dir) {
+		return filepath.Join(dir, "tetris"), nil
+	}
+
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("locate config dir: %w", err)
+	}
+	return filepath.Join(dir, "tetris"), nil
+}
+
+// Load reads the settings, fields missing in the file keep their defaults.
+// The error wraps fs.ErrNotExist when there is no file yet.
+func Load(path string) (Settings, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Settings{}, fmt.Errorf("read settings: %w", err)
+	}
+
+	s := Default()
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&s); err != nil {
+		return Settings{}, fmt.Errorf("parse settings %s: %w", path, err)
+	}
+	if err := s.Validate(); err != nil {
+		return Settings{}, fmt.Errorf("%s: %w", path, err)
+	}
+
+	return s, nil
+}
+
+// Save writes the settings atomically, so a crash never leaves a half written file.
+func Save(path string, s Settings) error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encode settings: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("create settings dir: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), FileName+".*")
+	if err != nil {
+		return fmt.Errorf("save settings: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(append(data, '\n')); err != nil {
+		tmp.Close()
+		return fmt.Errorf("save settings: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("save settings: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("save settings: %w", err)
+	}
+
+	return nil
+}
+
+// Duration is time.Duration written as a string like "167ms" in the file.
+type Duration time.Duration
+
+func (d Duration) String() string {
+	return time.Duration(d).String()
+}
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("duration must be a string like \"150ms\": %w", err)
+	}
+
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
@@ -0,0 +1,148 @@
+package settings
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/opennikish/tetris/internal/keymap"
+)
+
+func TestDefaultIsValid(t *testing.T) {
+	eq(t, nil, Default().Validate())
+}
+
+func TestSaveAndLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tetris", FileName)
+
+	s := Default()
+	s.DAS = Duration(120 * time.Millisecond)
+	s.Keys = keymap.Config{Preset: "vim", Bindings: keymap.Bindings{keymap.HardDrop: {"enter"}}}
+	s.Ghost = true
+	if err := Save(path, s); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	eq(t, s.DAS, loaded.DAS)
+	eq(t, "vim", loaded.Keys.Preset)
+	eq(t, "enter", loaded.Keys.Bindings[keymap.HardDrop][0])
+	eq(t, true, loaded.Ghost)
+
+	entries, _ := os.ReadDir(filepath.Dir(path))
+	eq(t, 1, len(entries)) // no temp files left behind
+}
+
+func TestLoadKeepsDefaultsOfMissingFields(t *testing.T) {
+	path := writeFile(t, `{"arr": "0s", "preview": 3}`)
+
+	s, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	eq(t, Duration(0), s.ARR)
+	eq(t, 3, s.Preview)
+	eq(t, Default().DAS, s.DAS)
+}
+
+func TestLoadReportsAllProblems(t *testing.T) {
+	path := writeFile(t, `{"das": "-1s", "theme": "neon", "preview": 9, "keys": {"preset": "emacs"}}`)
+
+	_, err := Load(path)
+	if err == nil {
+		t.Fatal("expected error")
+	}
+	for _, msg := range []string{
+		"das must be in range 0s..500ms, got -1s",
+		`keys: unknown preset "emacs"`,
+		`theme must be one of ascii, blocks, classic, got "neon"`,
+		"preview must be in range 0..5, got 9",
+	} {
+		if !strings.Contains(err.Error(), msg) {
+			t.Errorf("expected %q in:\n%s", msg, err)
+		}
+	}
+}
+
+func TestLoadRejectsUnknownFieldsAndBadDurations(t *testing.T) {
+	_, err := Load(writeFile(t, `{"ghots": true}`))
+	if err == nil || !strings.Contains(err.Error(), `unknown field "ghots"`) {
+		t.Fatalf("expected unknown field error, got: %v", err)
+	}
+
+	_, err = Load(writeFile(t, `{"das": 100}`))
+	if err == nil || !strings.Contains(err.Error(), `duration must be a string like "150ms"`) {
+		t.Fatalf("expected duration error, got: %v", err)
+	}
+}
+
+func TestLoadMissingFile(t *testing.T) {
+	_, err := Load(filepath.Join(t.TempDir(), FileName))
+	eq(t, true, errors.Is(err, fs.ErrNotExist))
+}
+
+func TestDirHonoursXDG(t *testing.T) {
+	dir, err := Dir(func(key string) string {
+		if key == "XDG_CONFIG_HOME" {
+			return "/xdg"
+		}
+		return ""
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	eq(t, filepath.Join("/xdg", "tetris"), dir)
+}
+
+func TestOptionsKeepSettingsValid(t *testing.T) {
+	for _, opt := range Options {
+		s := Default()
+		for range 100 {
+			opt.Change(&s, 1)
+			if err := s.Validate(); err != nil {
+				t.Fatalf("%s up: %s", opt.Label, err)
+			}
+		}
+		for range 200 {
+			opt.Change(&s, -1)
+			if err := s.Validate(); err != nil {
+				t.Fatalf("%s down: %s", opt.Label, err)
+			}
+		}
+	}
+}
+
+func TestOptionsCycleKeyPresets(t *testing.T) {
+	s := Default()
+	keys := Options[4]
+	eq(t, "Keys", keys.Label)
+
+	keys.Change(&s, 1)
+	eq(t, "left-hand", keys.Value(s))
+	keys.Change(&s, -1)
+	keys.Change(&s, -1)
+	eq(t, "wasd", keys.Value(s)) // wraps around
+}
+
+func writeFile(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), FileName)
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func eq[T comparable](t *testing.T, expected, actual T) {
+	t.Helper()
+	if expected != actual {
+		t.Fatalf("expected: %v got: %v", expected, actual)
+	}
+}
@@ -0,0 +1,413 @@
+// Package spectate streams a game to watchers. The first frame a watcher gets is a snapshot of the game,
+// the following ones carry only what has changed since the previous frame. The frames are line-delimited JSON.
+package spectate
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/opennikish/tetris/internal/game"
+)
+
+const Version = 1
+
+// Frame types.
+const (
+	TypeSnapshot = "snapshot"
+	TypeDelta    = "delta"
+)
+
+const (
+	timeStep     = 100 * time.Millisecond // the game time goes to the watchers in these steps
+	backlog      = 64                     // frames waiting for a slow watcher before it's dropped
+	writeTimeout = 5 * time.Second
+)
+
+// State is what the watchers see of the game.
+type State struct {
+	Mode   string
+	Field  [][]game.CellKind // the visible cells, line by line from the top
+	Piece  [4]game.Point     // the falling tetromino
+	Next   [][4]game.Point
+	Score  int
+	Lines  int
+	Level  int
+	Time   time.Duration
+	Over   bool
+	Reason string
+}
+
+// Clone copies the state, the field included.
+func (s State) Clone() State {
+	s.Field = slices.Clone(s.Field)
+	for i := range s.Field {
+		s.Field[i] = slices.Clone(s.Field[i])
+	}
+	s.Next = slices.Clone(s.Next)
+	return s
+}
+
+// sameGame tells whether the frames of the states can be deltas, a new mode or field size takes a snapshot.
+func sameGame(a, b State) bool {
+	return a.Mode == b.Mode && len(a.Field) == len(b.Field) && len(a.Field) > 0 && len(a.Field[0]) == len(b.Field[0])
+}
+
+// Cell is a changed cell of the field.
+type Cell struct {
+	I     int  `json:"i"`
+	J     int  `json:"j"`
+	Block bool `json:"block,omitempty"`
+}
+
+// Frame is a line of the stream, the fields used depend on the type.
+type Frame struct {
+	Type    string          `json:"type"`
+	Version int             `json:"version,omitempty"`
+	Mode    string          `json:"mode,omitempty"`
+	Field   []string        `json:"field,omitempty"` // snapshot: lines of '#' for blocks and '.' for empty cells
+	Cells   []Cell          `json:"cells,omitempty"` // delta
+	Piece   [4]game.Point   `json:"piece"`
+	Next    [][4]game.Point `json:"next,omitempty"`
+	Score   int             `json:"score"`
+	Lines   int             `json:"lines"`
+	Level   int             `json:"level"`
+	Time    time.Duration   `json:"time"`
+	Over    bool            `json:"over,omitempty"`
+	Reason  string          `json:"reason,omitempty"`
+}
+
+func snapshot(s State) Frame {
+	f := frame(TypeSnapshot, s)
+	f.Version, f.Mode = Version, s.Mode
+	for _, line := range s.Field {
+		var sb strings.Builder
+		for _, ck := range line {
+			if ck == game.CellBlock {
+				sb.WriteByte('#')
+			} else {
+				sb.WriteByte('.')
+			}
+		}
+		f.Field = append(f.Field, sb.String())
+	}
+	return f
+}
+
+func delta(prev, s State) Frame {
+	f := frame(TypeDelta, s)
+	for i, line := range s.Field {
+		for j, ck := range line {
+			if ck != prev.Field[i][j] {
+				f.Cells = append(f.Cells, Cell{I: i, J: j, Block: ck == game.CellBlock})
+			}
+		}
+	}
+	return f
+}
+
+func frame(typ string, s State) Frame {
+	return Frame{
+		Type:   typ,
+		Piece:  s.Piece,
+		Next:   s.Next,
+		Score:  s.Score,
+		Lines:  s.Lines,
+		Level:  s.Level,
+		Time:   s.Time,
+		Over:   s.Over,
+		Reason: s.Reason,
+	}
+}
+
+// apply returns the state after the frame, a delta changes the previous state.
+func apply(prev *State, f Frame) (State, error) {
+	var s State
+	switch f.Type {
+	case TypeSnapshot:
+		if f.Version != Version {
+			return State{}, fmt.Errorf("unsupported broadcast version %d, want %d", f.Version, Version)
+		}
+		if len(f.Field) == 0 || len(f.Field[0]) == 0 {
+			return State{}, errors.New("snapshot without the field")
+		}
+		s.Mode = f.Mode
+		for _, line := range f.Field {
+			if len(line) != len(f.Field[0]) {
+				return State{}, errors.New("snapshot lines differ in width")
+			}
+			cells := make([]game.CellKind, len(line))
+			for j := range line {
+				cells[j] = game.CellEmpty
+				if line[j] == '#' {
+					cells[j] = game.CellBlock
+				}
+			}
+			s.Field = append(s.Field, cells)
+		}
+	case TypeDelta:
+		if prev == nil {
+			return State{}, errors.New("delta before the snapshot")
+		}
+		s = prev.Clone()
+		for _, c := range f.Cells {
+			if c.I < 0 || c.I >= len(s.Field) || c.J < 0 || c.J >= len(s.Field[c.I]) {
+				return State{}, fmt.Errorf("cell %d,%d out of the field", c.I, c.J)
+			}
+			s.Field[c.I][c.J] = game.CellEmpty
+			if c.Block {
+				s.Field[c.I][c.J] = game.CellBlock
+			}
+		}
+	default:
+		return State{}, fmt.Errorf("unknown frame type %q", f.Type)
+	}
+
+	for _, p := range f.Piece {
+		if p.X < 0 || p.X >= len(s.Field[0]) || p.Y < 0 || p.Y > len(s.Field) {
+			return State{}, fmt.Errorf("tetromino point %d,%d out of the field", p.X, p.Y)
+		}
+	}
+	s.Piece, s.Next = f.Piece, f.Next
+	s.Score, s.Lines, s.Level, s.Time = f.Score, f.Lines, f.Level, f.Time
+	s.Over, s.Reason = f.Over, f.Reason
+	return s, nil
+}
+
+func equal(a, b State) bool {
+	if !sameGame(a, b) || a.Piece != b.Piece || !slices.Equal(a.Next, b.Next) {
+		return false
+	}
+	if a.Score != b.Score || a.Lines != b.Lines || a.Level != b.Level || a.Time != b.Time || a.Over != b.Over || a.Reason != b.Reason {
+		return false
+	}
+	for i := range a.Field {
+		if !slices.Equal(a.Field[i], b.Field[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// network splits the address into the network and the address of it: unix:/path is a Unix socket, the rest TCP.
+func network(addr string) (string, string) {
+	if path, ok := strings.CutPrefix(addr, "unix:"); ok {
+		return "unix", path
+	}
+	return "tcp", addr
+}
+
+// Broadcaster sends the published states to every connected watcher, the game never waits for them:
+// a watcher that can't keep up is dropped.
+type Broadcaster struct {
+	ln       net.Listener
+	mu       sync.Mutex
+	last     *State
+	watchers map[*watcher]bool
+	closed   bool
+	logger   *slog.Logger
+}
+
+type watcher struct {
+	conn   net.Conn
+	frames chan []byte
+}
+
+// Listen starts waiting for watchers on the address, host:port for TCP or unix:/path for a Unix socket.
+func Listen(addr string) (*Broadcaster, error) {
+	ln, err := net.Listen(network(addr))
+	if err != nil {
+		return nil, fmt.Errorf("listen: %w", err)
+	}
+
+	b := &Broadcaster{
+		ln:       ln,
+		watchers: map[*watcher]bool{},
+		logger:   slog.New(slog.NewTextHandler(io.Discard, nil)),
+	}
+	go b.accept()
+	return b, nil
+}
+
+// SetLogger logs the watchers coming and going, it's discarded by default.
+func (b *Broadcaster) SetLogger(l *slog.Logger) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.logger = l
+}
+
+func (b *Broadcaster) Addr() net.Addr {
+	return b.ln.Addr()
+}
+
+func (b *Broadcaster) accept() {
+	for {
+		conn, err := b.ln.Accept()
+		if err != nil {
+			return // closed
+		}
+
+		w := &watcher{conn: conn, frames: make(chan []byte, backlog)}
+		b.mu.Lock()
+		if b.closed {
+			b.mu.Unlock()
+			conn.Close()
+			return
+		}
+		b.logger.Info("watcher joined", "remote", conn.RemoteAddr().String())
+		if b.last != nil {
+			w.frames <- encode(snapshot(*b.last))
+		}
+		b.watchers[w] = true
+		b.mu.Unlock()
+
+		go b.write(w)
+	}
+}
+
+func (b *Broadcaster) write(w *watcher) {
+	defer w.conn.Close()
+	for data := range w.frames {
+		w.conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+		if _, err := w.conn.Write(data); err != nil {
+			b.mu.Lock()
+			b.drop(w, err)
+			b.mu.Unlock()
+			w.conn.Close()
+		}
+	}
+}
+
+// drop forgets the watcher and lets its writer finish, b.mu must be held.
+func (b *Broadcaster) drop(w *watcher, err error) {
+	if !b.watchers[w] {
+		return
+	}
+	b.logger.Info("watcher left", "remote", w.conn.RemoteAddr().String(), "err", err)
+	delete(b.watchers, w)
+	close(w.frames)
+}
+
+// Publish sends the state to the watchers as a delta of the previous one. It keeps the state,
+// the caller must not change it afterwards.
+func (b *Broadcaster) Publish(s State) {
+	s.Time = s.Time.Truncate(timeStep)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return
+	}
+
+	var f Frame
+	switch {
+	case b.last == nil || !sameGame(*b.last, s):
+		f = snapshot(s)
+	case equal(*b.last, s):
+		return
+	default:
+		f = delta(*b.last, s)
+	}
+	b.last = &s
+
+	data := encode(f)
+	for w := range b.watchers {
+		select {
+		case w.frames <- data:
+		default:
+			b.drop(w, errors.New("too slow"))
+		}
+	}
+}
+
+// Close stops the broadcast and disconnects the watchers.
+func (b *Broadcaster) Close() error {
+	b.mu.Lock()
+	b.closed = true
+	for w := range b.watchers {
+		b.drop(w, nil)
+	}
+	b.mu.Unlock()
+	return b.ln.Close()
+}
+
+func encode(f Frame) []byte {
+	var buf bytes.Buffer
+	if err := json.NewEncoder(&buf).Encode(f); err != nil {
+		panic(fmt.Sprintf("encode frame: %s", err)) // plain data always encodes
+	}
+	return buf.Bytes()
+}
+
+// Conn is the watching side of the broadcast.
+type Conn struct {
+	conn net.Conn
+	err  error
+}
+
+// Dial connects to the broadcast on the address, see Listen.
+func Dial(ctx context.Context, addr string) (*Conn, error) {
+	var d net.Dialer
+	netw, address := network(addr)
+	conn, err := d.DialContext(ctx, netw, address)
+	if err != nil {
+		return nil, fmt.Errorf("dial: %w", err)
+	}
+	return &Conn{conn: conn}, nil
+}
+
+// Receive sends the state of the game after every frame. The channel is closed when the broadcast ends,
+// Err tells why.
+func (c *Conn) Receive(ctx context.Context) <-chan State {
+	out := make(chan State)
+
+	go func() {
+		defer close(out)
+		stop := context.AfterFunc(ctx, func() { c.conn.SetReadDeadline(time.Now()) })
+		defer stop()
+
+		dec := json.NewDecoder(c.conn)
+		var last *State
+		for {
+			var f Frame
+			if err := dec.Decode(&f); err != nil {
+				if ctx.Err() == nil && !errors.Is(err, io.EOF) {
+					c.err = fmt.Errorf("receive: %w", err)
+				}
+				return
+			}
+			s, err := apply(last, f)
+			if err != nil {
+				c.err = err
+				return
+			}
+			last = &s
+
+			select {
+			case out <- s.Clone():
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return out
+}
+
+// Err returns why the broadcast ended, nil when the player stopped it, it's valid once the Receive channel is closed.
+func (c *Conn) Err() error {
+	return c.err
+}
+
+func (c *Conn) Close() error {
+	return c.conn.Close()
+}
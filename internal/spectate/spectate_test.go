@@ -0,0 +1,150 @@
+package spectate
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/opennikish/tetris/internal/game"
+)
+
+func state(blocks ...game.Point) State {
+	s := State{
+		Mode:  "marathon",
+		Field: make([][]game.CellKind, 4),
+		Piece: [4]game.Point{{X: 0, Y: 0}, {X: 1, Y: 0}, {X: 2, Y: 0}, {X: 3, Y: 0}},
+		Level: 1,
+	}
+	for i := range s.Field {
+		s.Field[i] = []game.CellKind{game.CellEmpty, game.CellEmpty, game.CellEmpty, game.CellEmpty}
+	}
+	for _, p := range blocks {
+		s.Field[p.Y][p.X] = game.CellBlock
+	}
+	return s
+}
+
+func readFrame(t *testing.T, r *bufio.Reader) Frame {
+	t.Helper()
+	line, err := r.ReadBytes('\n')
+	if err != nil {
+		t.Fatal(err)
+	}
+	var f Frame
+	if err := json.Unmarshal(line, &f); err != nil {
+		t.Fatal(err)
+	}
+	return f
+}
+
+func TestLateWatcherGetsSnapshotThenDeltas(t *testing.T) {
+	b, err := Listen("127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Close()
+	b.Publish(state(game.Point{X: 0, Y: 3}))
+
+	conn, err := net.Dial("tcp", b.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	r := bufio.NewReader(conn)
+	for !waitWatchers(b) {
+		time.Sleep(time.Millisecond)
+	}
+
+	f := readFrame(t, r)
+	eq(t, TypeSnapshot, f.Type)
+	eq(t, Version, f.Version)
+	eq(t, "....,....,....,#...", strings.Join(f.Field, ","))
+
+	s := state(game.Point{X: 0, Y: 3}, game.Point{X: 1, Y: 3})
+	s.Score = 10
+	b.Publish(s)
+	b.Publish(s) // nothing has changed
+	s.Over, s.Reason = true, "topped out"
+	b.Publish(s)
+
+	f = readFrame(t, r)
+	eq(t, TypeDelta, f.Type)
+	eq(t, 1, len(f.Cells))
+	eq(t, Cell{I: 3, J: 1, Block: true}, f.Cells[0])
+	eq(t, 10, f.Score)
+
+	f = readFrame(t, r)
+	eq(t, TypeDelta, f.Type)
+	eq(t, 0, len(f.Cells))
+	eq(t, true, f.Over)
+}
+
+func TestConnRebuildsStates(t *testing.T) {
+	b, err := Listen("unix:" + filepath.Join(t.TempDir(), "tetris.sock"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	c, err := Dial(ctx, "unix:"+b.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	states := c.Receive(ctx)
+
+	first := state()
+	for !waitWatchers(b) {
+		time.Sleep(time.Millisecond)
+	}
+	b.Publish(first)
+	second := state(game.Point{X: 2, Y: 1})
+	second.Time = 1234 * time.Millisecond
+	b.Publish(second)
+
+	eq(t, true, equal(first, <-states))
+	got := <-states
+	eq(t, game.CellBlock, got.Field[1][2])
+	eq(t, 1200*time.Millisecond, got.Time)
+
+	b.Close()
+	_, ok := <-states
+	eq(t, false, ok)
+	eq(t, nil, c.Err())
+}
+
+func TestApplyRejectsBadFrames(t *testing.T) {
+	_, err := apply(nil, Frame{Type: TypeSnapshot, Version: Version + 1, Field: []string{"...."}})
+	eq(t, "unsupported broadcast version 2, want 1", err.Error())
+
+	_, err = apply(nil, Frame{Type: TypeDelta})
+	eq(t, "delta before the snapshot", err.Error())
+
+	prev := state()
+	_, err = apply(&prev, Frame{Type: TypeDelta, Cells: []Cell{{I: 4, J: 0}}})
+	eq(t, "cell 4,0 out of the field", err.Error())
+
+	_, err = apply(&prev, Frame{Type: TypeDelta, Piece: [4]game.Point{{X: 9, Y: 0}}})
+	eq(t, "tetromino point 9,0 out of the field", err.Error())
+}
+
+func waitWatchers(b *Broadcaster) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.watchers) > 0
+}
+
+func eq[T comparable](t *testing.T, expected, actual T) {
+	t.Helper()
+	if expected != actual {
+		t.Fatalf("expected: %v got: %v", expected, actual)
+	}
+}
@@ -0,0 +1,300 @@
+// Package telnet lets plain telnet clients stand in for the terminal: the connection is switched into character
+// mode without the local echo, the client reports its window size (NAWS) and terminal type (TTYPE), and the protocol
+// is stripped off the input.
+package telnet
+
+import (
+	"bytes"
+	"errors"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Commands and options of RFC 854, 857, 858, 1073 and 1091.
+const (
+	cmdSE   = 240
+	cmdIP   = 244 // interrupt process
+	cmdSB   = 250
+	cmdWill = 251
+	cmdWont = 252
+	cmdDo   = 253
+	cmdDont = 254
+	cmdIAC  = 255
+
+	optEcho  = 1
+	optSGA   = 3 // suppress go ahead, with the echo it makes the character mode
+	optTType = 24
+	optNAWS  = 31
+
+	ttypeIs   = 0
+	ttypeSend = 1
+)
+
+const (
+	ctrlC        = 3
+	writeTimeout = 10 * time.Second
+)
+
+// Parser states of the input.
+const (
+	stateData = iota
+	stateIAC
+	stateOption
+	stateSub
+	stateSubIAC
+)
+
+// Conn is the input, the output and the mode of the terminal on the client side.
+type Conn struct {
+	conn        net.Conn
+	wmu         sync.Mutex // guards the writes, the parser answers the client while the game draws
+	mu          sync.Mutex // guards the size and the terminal type
+	lines, cols int
+	termType    string
+	typeSettled bool // the client has told its terminal type or refused to
+	resized     chan struct{}
+	interrupted chan struct{}
+	raw         bool
+
+	buf     []byte
+	pending []byte // parsed input not read yet
+	state   int
+	cmd     byte
+	sub     []byte
+	cr      bool // the last input byte was CR, the LF or NUL after it is dropped
+}
+
+func NewConn(conn net.Conn) *Conn {
+	return &Conn{
+		conn:        conn,
+		resized:     make(chan struct{}, 1),
+		interrupted: make(chan struct{}, 1),
+		buf:         make([]byte, 512),
+	}
+}
+
+// Negotiate asks for the character mode, the window size and the terminal type, and waits up to the timeout
+// for the client to tell them. Clients that don't still get the game, the window size and the type are unknown then.
+func (c *Conn) Negotiate(timeout time.Duration) error {
+	if err := c.MakeRaw(); err != nil {
+		return err
+	}
+	if err := c.write([]byte{cmdIAC, cmdDo, optNAWS, cmdIAC, cmdDo, optTType}); err != nil {
+		return err
+	}
+
+	c.conn.SetReadDeadline(time.Now().Add(timeout))
+	defer c.conn.SetReadDeadline(time.Time{})
+	for {
+		if _, _, err := c.Size(); err == nil && c.settled() {
+			return nil
+		}
+		n, err := c.conn.Read(c.buf)
+		c.parse(c.buf[:n])
+		if errors.Is(err, os.ErrDeadlineExceeded) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// Read returns the input of the client without the protocol.
+func (c *Conn) Read(p []byte) (int, error) {
+	for len(c.pending) == 0 {
+		n, err := c.conn.Read(c.buf)
+		c.parse(c.buf[:n])
+		if err != nil && len(c.pending) == 0 {
+			return 0, err
+		}
+	}
+	n := copy(p, c.pending)
+	c.pending = c.pending[n:]
+	return n, nil
+}
+
+// SetReadDeadline lets the terminal interrupt a blocked Read.
+func (c *Conn) SetReadDeadline(t time.Time) error {
+	return c.conn.SetReadDeadline(t)
+}
+
+func (c *Conn) parse(data []byte) {
+	for _, b := range data {
+		switch c.state {
+		case stateData:
+			switch {
+			case b == cmdIAC:
+				c.state = stateIAC
+			case c.cr && (b == '\n' || b == 0):
+				// Enter comes as CR LF or CR NUL
+			case b == ctrlC:
+				c.interrupt()
+			default:
+				c.pending = append(c.pending, b)
+			}
+			c.cr = b == '\r'
+		case stateIAC:
+			c.state = stateData
+			switch b {
+			case cmdIAC:
+				c.pending = append(c.pending, b)
+			case cmdWill, cmdWont, cmdDo, cmdDont:
+				c.cmd, c.state = b, stateOption
+			case cmdSB:
+				c.sub, c.state = c.sub[:0], stateSub
+			case cmdIP:
+				c.interrupt()
+			}
+		case stateOption:
+			c.state = stateData
+			c.answer(c.cmd, b)
+		case stateSub:
+			if b == cmdIAC {
+				c.state = stateSubIAC
+			} else {
+				c.sub = append(c.sub, b)
+			}
+		case stateSubIAC:
+			if b == cmdIAC {
+				c.sub, c.state = append(c.sub, b), stateSub
+				continue
+			}
+			c.state = stateData
+			c.subnegotiation(c.sub)
+		}
+	}
+}
+
+// answer refuses the options the client offers or asks for, but the ones the game has asked for itself.
+// Agreeing and refusing answers need no reply, which keeps the negotiation from looping. The terminal type
+// the client agrees to tell is asked for right away.
+func (c *Conn) answer(cmd, opt byte) {
+	switch {
+	case cmd == cmdWill && opt == optTType:
+		c.write([]byte{cmdIAC, cmdSB, optTType, ttypeSend, cmdIAC, cmdSE})
+	case cmd == cmdWont && opt == optTType:
+		c.mu.Lock()
+		c.typeSettled = true
+		c.mu.Unlock()
+	case cmd == cmdWill && opt != optNAWS && opt != optSGA:
+		c.write([]byte{cmdIAC, cmdDont, opt})
+	case cmd == cmdDo && opt != optEcho && opt != optSGA:
+		c.write([]byte{cmdIAC, cmdWont, opt})
+	}
+}
+
+func (c *Conn) subnegotiation(sub []byte) {
+	if len(sub) >= 2 && sub[0] == optTType && sub[1] == ttypeIs {
+		c.mu.Lock()
+		c.termType = strings.ToLower(string(sub[2:])) // clients tend to shout it, terminfo names are lower case
+		c.typeSettled = true
+		c.mu.Unlock()
+		return
+	}
+	if len(sub) < 5 || sub[0] != optNAWS {
+		return
+	}
+	c.mu.Lock()
+	c.cols = int(sub[1])<<8 | int(sub[2])
+	c.lines = int(sub[3])<<8 | int(sub[4])
+	c.mu.Unlock()
+
+	select {
+	case c.resized <- struct{}{}:
+	default:
+	}
+}
+
+func (c *Conn) interrupt() {
+	select {
+	case c.interrupted <- struct{}{}:
+	default:
+	}
+}
+
+// Interrupted notifies about Ctrl-C, which the client sends as is in the character mode.
+func (c *Conn) Interrupted() <-chan struct{} {
+	return c.interrupted
+}
+
+// Size returns the window size reported by the client.
+func (c *Conn) Size() (lines, cols int, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.cols == 0 || c.lines == 0 {
+		return 0, 0, errors.New("the telnet client hasn't reported the window size")
+	}
+	return c.lines, c.cols, nil
+}
+
+// TermType returns the terminal type reported by the client, e.g. xterm-256color, empty if it hasn't told it.
+func (c *Conn) TermType() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.termType
+}
+
+func (c *Conn) settled() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.typeSettled
+}
+
+// Resized notifies about the window size reports of the client.
+func (c *Conn) Resized() <-chan struct{} {
+	return c.resized
+}
+
+// Write escapes the IAC bytes and ends the lines with CR LF the way the protocol wants them.
+// A client that doesn't take the output in time is disconnected, which ends its reading too.
+func (c *Conn) Write(p []byte) (int, error) {
+	var out bytes.Buffer
+	out.Grow(len(p) + 16)
+	for _, b := range p {
+		switch b {
+		case cmdIAC:
+			out.WriteByte(cmdIAC)
+		case '\n':
+			out.WriteByte('\r')
+		}
+		out.WriteByte(b)
+	}
+	if err := c.write(out.Bytes()); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+func (c *Conn) write(p []byte) error {
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+
+	c.conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+	if _, err := c.conn.Write(p); err != nil {
+		c.conn.Close()
+		return err
+	}
+	return nil
+}
+
+// MakeRaw switches the client into the character mode with the echo done by the server, that is by nobody.
+func (c *Conn) MakeRaw() error {
+	if c.raw {
+		return nil
+	}
+	c.raw = true
+	return c.write([]byte{cmdIAC, cmdWill, optEcho, cmdIAC, cmdWill, optSGA})
+}
+
+// Restore gives the echo back to the client.
+func (c *Conn) Restore() error {
+	if !c.raw {
+		return nil
+	}
+	c.raw = false
+	return c.write([]byte{cmdIAC, cmdWont, optEcho})
+}
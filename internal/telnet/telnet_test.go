@@ -0,0 +1,114 @@
+package telnet
+
+import (
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+func pipe(t *testing.T) (*Conn, net.Conn) {
+	t.Helper()
+	server, client := net.Pipe()
+	t.Cleanup(func() {
+		server.Close()
+		client.Close()
+	})
+	return NewConn(server), client
+}
+
+func readN(t *testing.T, r io.Reader, n int) string {
+	t.Helper()
+	buf := make([]byte, n)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		t.Fatal(err)
+	}
+	return string(buf)
+}
+
+func TestNegotiateReadsWindowSize(t *testing.T) {
+	c, client := pipe(t)
+	done := make(chan error)
+	go func() {
+		done <- c.Negotiate(time.Second)
+	}()
+
+	eq(t, "\xff\xfb\x01\xff\xfb\x03\xff\xfd\x1f\xff\xfd\x18", readN(t, client, 12)) // WILL ECHO, WILL SGA, DO NAWS, DO TTYPE
+	client.Write([]byte("\xff\xfb\x1f\xff\xfa\x1f\x00\x64\x00\x1e\xff\xf0\xff\xfb\x18"))
+	eq(t, "\xff\xfa\x18\x01\xff\xf0", readN(t, client, 6)) // SB TTYPE SEND
+	client.Write([]byte("\xff\xfa\x18\x00XTERM-256COLOR\xff\xf0ab"))
+	eq(t, nil, <-done)
+	eq(t, "xterm-256color", c.TermType())
+
+	lines, cols, err := c.Size()
+	eq(t, nil, err)
+	eq(t, 30, lines)
+	eq(t, 100, cols)
+	select {
+	case <-c.Resized():
+	default:
+		t.Fatal("no resize notification")
+	}
+
+	go client.Write([]byte("c"))
+	buf := make([]byte, 8)
+	n, _ := c.Read(buf)
+	eq(t, "ab", string(buf[:n])) // typed during the negotiation
+}
+
+func TestNegotiateWithoutWindowSize(t *testing.T) {
+	c, client := pipe(t)
+	go io.Copy(io.Discard, client)
+
+	eq(t, nil, c.Negotiate(10*time.Millisecond))
+	_, _, err := c.Size()
+	eq(t, "the telnet client hasn't reported the window size", err.Error())
+	eq(t, "", c.TermType())
+}
+
+func TestReadStripsProtocol(t *testing.T) {
+	c, client := pipe(t)
+	go client.Write([]byte("a\xff\xffb\r\n\r\x00\xff\xfd\x18c\x03d"))
+
+	replies := make(chan string)
+	go func() {
+		buf := make([]byte, 3)
+		io.ReadFull(client, buf)
+		replies <- string(buf)
+	}()
+
+	var got []byte
+	buf := make([]byte, 16)
+	for len(got) < 6 {
+		n, err := c.Read(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, buf[:n]...)
+	}
+	eq(t, "a\xffb\r\rc", string(got[:6]))
+	eq(t, "\xff\xfc\x18", <-replies) // WONT TERMINAL-TYPE
+	select {
+	case <-c.Interrupted():
+	case <-time.After(time.Second):
+		t.Fatal("Ctrl-C didn't interrupt")
+	}
+}
+
+func TestWriteEscapesAndEndsLines(t *testing.T) {
+	c, client := pipe(t)
+	written := make(chan int)
+	go func() {
+		n, _ := c.Write([]byte("a\xff\nb"))
+		written <- n
+	}()
+	eq(t, "a\xff\xff\r\nb", readN(t, client, 6))
+	eq(t, 4, <-written)
+}
+
+func eq[T comparable](t *testing.T, expected, actual T) {
+	t.Helper()
+	if expected != actual {
+		t.Fatalf("expected: %v got: %v", expected, actual)
+	}
+}
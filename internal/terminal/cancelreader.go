@@ -0,0 +1,88 @@
+package terminal
+
+import (
+	"errors"
+	"io"
+	"os"
+	"sync/atomic"
+	"time"
+)
+
+var errCanceled = errors.New("read canceled")
+
+// cancelReader is a reader whose blocked Read can be interrupted, so the reading goroutine doesn't outlive the game
+// and doesn't swallow keystrokes typed into the shell after the exit.
+type cancelReader interface {
+	io.Reader
+	// Cancel makes the blocked and all future Read calls return errCanceled.
+	Cancel()
+	// Close releases the resources, called after the last Read returned.
+	Close() error
+}
+
+// newCancelReader picks the way to interrupt reads: deadlines for pollable files and network connections,
+// select with a wake-up pipe for terminal devices. Other readers can't be interrupted, reading stops on the next input.
+func newCancelReader(r io.Reader) (cancelReader, bool) {
+	if dr, ok := r.(deadlineReader); ok && dr.SetReadDeadline(time.Time{}) == nil {
+		return &deadlineCancelReader{r: dr}, true
+	}
+
+	if f, ok := r.(*os.File); ok {
+		if pr, err := newPollReader(int(f.Fd())); err == nil {
+			return pr, true
+		}
+	}
+
+	return &plainReader{r: r}, false
+}
+
+type deadlineReader interface {
+	io.Reader
+	SetReadDeadline(t time.Time) error
+}
+
+type deadlineCancelReader struct {
+	r        deadlineReader
+	canceled atomic.Bool
+}
+
+func (r *deadlineCancelReader) Read(p []byte) (int, error) {
+	if r.canceled.Load() {
+		return 0, errCanceled
+	}
+
+	n, err := r.r.Read(p)
+	if errors.Is(err, os.ErrDeadlineExceeded) && r.canceled.Load() {
+		return n, errCanceled
+	}
+	return n, err
+}
+
+func (r *deadlineCancelReader) Cancel() {
+	r.canceled.Store(true)
+	r.r.SetReadDeadline(time.Now())
+}
+
+func (r *deadlineCancelReader) Close() error {
+	return r.r.SetReadDeadline(time.Time{})
+}
+
+type plainReader struct {
+	r        io.Reader
+	canceled atomic.Bool
+}
+
+func (r *plainReader) Read(p []byte) (int, error) {
+	if r.canceled.Load() {
+		return 0, errCanceled
+	}
+	return r.r.Read(p)
+}
+
+func (r *plainReader) Cancel() {
+	r.canceled.Store(true)
+}
+
+func (r *plainReader) Close() error {
+	return nil
+}
@@ -0,0 +1,87 @@
+package terminal
+
+import (
+	"context"
+	"io"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestWatchInputStopsReadingOnCancel(t *testing.T) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	defer w.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	term := NewTerminal(r, io.Discard, nil)
+	events, errc := term.WatchInput(ctx)
+
+	w.Write([]byte("a"))
+	eq(t, Event(Key{Kind: Letter, Char: 'a'}), <-events)
+
+	cancel()
+	awaitClosed(t, errc)
+
+	// the keystroke typed after the shutdown stays in the input for the next reader
+	w.Write([]byte("b"))
+	buf := make([]byte, 1)
+	n, err := r.Read(buf)
+	eq(t, nil, err)
+	eq(t, "b", string(buf[:n]))
+}
+
+func TestPollReaderCancelInterruptsBlockedRead(t *testing.T) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	defer w.Close()
+
+	pr, err := newPollReader(int(r.Fd()))
+	if err != nil {
+		t.Skipf("poll reader isn't supported: %s", err)
+	}
+	defer pr.Close()
+
+	w.Write([]byte("a"))
+	buf := make([]byte, 8)
+	n, err := pr.Read(buf)
+	eq(t, nil, err)
+	eq(t, "a", string(buf[:n]))
+
+	done := make(chan error)
+	go func() {
+		_, err := pr.Read(buf)
+		done <- err
+	}()
+
+	time.Sleep(5 * time.Millisecond)
+	pr.Cancel()
+
+	select {
+	case err := <-done:
+		eq(t, errCanceled, err)
+	case <-time.After(time.Second):
+		t.Fatal("read wasn't interrupted")
+	}
+}
+
+func awaitClosed(t *testing.T, errc <-chan error) {
+	t.Helper()
+	timeout := time.After(time.Second)
+	for {
+		select {
+		case _, ok := <-errc:
+			if !ok {
+				return
+			}
+		case <-timeout:
+			t.Fatal("reader didn't stop")
+		}
+	}
+}
@@ -0,0 +1,458 @@
+package terminal
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+type KeyKind uint8
+
+const (
+	Left KeyKind = iota
+	Right
+	Up
+	Letter
+	Down
+	Home
+	End
+	PageUp
+	PageDown
+	Insert
+	Delete
+	Enter
+	Tab
+	Backspace
+	Esc
+	F1
+	F2
+	F3
+	F4
+	F5
+	F6
+	F7
+	F8
+	F9
+	F10
+	F11
+	F12
+
+	keyboardFlags // reply to the keyboard protocol query, flags are in Char, never leaves the package
+)
+
+var kkNames = map[KeyKind]string{
+	Left:      "left",
+	Right:     "right",
+	Up:        "up",
+	Letter:    "letter",
+	Down:      "down",
+	Home:      "home",
+	End:       "end",
+	PageUp:    "pgup",
+	PageDown:  "pgdown",
+	Insert:    "insert",
+	Delete:    "delete",
+	Enter:     "enter",
+	Tab:       "tab",
+	Backspace: "backspace",
+	Esc:       "esc",
+	F1:        "f1",
+	F2:        "f2",
+	F3:        "f3",
+	F4:        "f4",
+	F5:        "f5",
+	F6:        "f6",
+	F7:        "f7",
+	F8:        "f8",
+	F9:        "f9",
+	F10:       "f10",
+	F11:       "f11",
+	F12:       "f12",
+}
+
+func (kk KeyKind) String() string {
+	return kkNames[kk]
+}
+
+type Modifier uint8
+
+const (
+	ModShift Modifier = 1 << iota
+	ModAlt
+	ModCtrl
+)
+
+// KeyAction tells whether the key is pressed, repeated or released.
+// Only terminals speaking the kitty keyboard protocol report repeats and releases.
+type KeyAction uint8
+
+const (
+	Press KeyAction = iota
+	Repeat
+	Release
+)
+
+type Key struct {
+	Kind   KeyKind
+	Char   rune // set for Letter only
+	Mod    Modifier
+	Action KeyAction
+}
+
+// String formats the key as "ctrl+alt+shift+name", e.g. "ctrl+left" or "q".
+func (k Key) String() string {
+	var sb strings.Builder
+	if k.Mod&ModCtrl != 0 {
+		sb.WriteString("ctrl+")
+	}
+	if k.Mod&ModAlt != 0 {
+		sb.WriteString("alt+")
+	}
+	if k.Mod&ModShift != 0 {
+		sb.WriteString("shift+")
+	}
+
+	switch {
+	case k.Kind != Letter && k.Kind.String() == "":
+		fmt.Fprintf(&sb, "key(%d)", k.Kind)
+	case k.Kind != Letter:
+		sb.WriteString(k.Kind.String())
+	case k.Char == ' ':
+		sb.WriteString("space")
+	default:
+		sb.WriteRune(k.Char)
+	}
+
+	return sb.String()
+}
+
+// ParseKey parses the format produced by Key.String, e.g. "ctrl+left", "space" or "q".
+// Names and modifiers are case insensitive, a single character stands for itself.
+func ParseKey(s string) (Key, error) {
+	var key Key
+	rest := s
+	for {
+		prefix, tail, found := strings.Cut(rest, "+")
+		if !found || tail == "" {
+			break
+		}
+		switch strings.ToLower(prefix) {
+		case "ctrl":
+			key.Mod |= ModCtrl
+		case "alt":
+			key.Mod |= ModAlt
+		case "shift":
+			key.Mod |= ModShift
+		default:
+			return Key{}, fmt.Errorf("parse key %q: unknown modifier %q", s, prefix)
+		}
+		rest = tail
+	}
+
+	if utf8.RuneCountInString(rest) == 1 {
+		key.Kind = Letter
+		key.Char, _ = utf8.DecodeRuneInString(rest)
+		return key, nil
+	}
+
+	name := strings.ToLower(rest)
+	if name == "space" {
+		key.Kind, key.Char = Letter, ' '
+		return key, nil
+	}
+	for kk, kkName := range kkNames {
+		if kk != Letter && kkName == name {
+			key.Kind = kk
+			return key, nil
+		}
+	}
+
+	return Key{}, fmt.Errorf("parse key %q: unknown key %q", s, rest)
+}
+
+// escTimeout is how long the decoder waits for the rest of an escape sequence before reporting a lone Esc key.
+const escTimeout = 50 * time.Millisecond
+
+// maxSeqLen bounds the length of a control sequence, longer garbage is dropped.
+const maxSeqLen = 32
+
+// keyDecoder turns a stream of bytes from the terminal into keys.
+// Sequences split between reads are kept until the rest arrives.
+type keyDecoder struct {
+	buf []byte
+}
+
+// Feed decodes the given input together with the leftover of the previous calls.
+func (d *keyDecoder) Feed(p []byte) []Event {
+	d.buf = append(d.buf, p...)
+
+	var events []Event
+	for len(d.buf) > 0 {
+		e, n := decodeEvent(d.buf)
+		if n == 0 {
+			break // incomplete, wait for more input
+		}
+		if e != nil {
+			events = append(events, e)
+		}
+		d.buf = d.buf[n:]
+	}
+
+	if len(d.buf) == 0 {
+		d.buf = nil
+	}
+
+	return events
+}
+
+// Pending reports whether an incomplete sequence waits for more input.
+func (d *keyDecoder) Pending() bool {
+	return len(d.buf) > 0
+}
+
+// Flush gives up waiting for the rest of an incomplete sequence.
+// The leading Esc is reported as a key on its own and the rest is decoded again.
+func (d *keyDecoder) Flush() []Event {
+	if len(d.buf) == 0 {
+		return nil
+	}
+
+	if d.buf[0] != '\033' {
+		d.buf = nil // truncated UTF-8
+		return nil
+	}
+
+	rest := d.buf[1:]
+	d.buf = nil
+	events := []Event{Key{Kind: Esc}}
+	events = append(events, d.Feed(rest)...)
+	return append(events, d.Flush()...)
+}
+
+// decodeEvent decodes the first event of the input and returns how many bytes it took.
+// Zero length means the input is an incomplete sequence, nil event means unsupported sequence.
+func decodeEvent(b []byte) (Event, int) {
+	if m, n, ok := decodeMouse(b); n != 0 {
+		if !ok {
+			return nil, n
+		}
+		return m, n
+	}
+
+	key, n, ok := decodeKey(b)
+	if !ok {
+		return nil, n
+	}
+	return key, n
+}
+
+// decodeMouse decodes SGR mouse sequences, zero length means the input isn't a mouse sequence or is incomplete.
+func decodeMouse(b []byte) (Mouse, int, bool) {
+	if len(b) < 3 || b[0] != '\033' || b[1] != '[' || b[2] != '<' {
+		return Mouse{}, 0, false
+	}
+
+	end := 3
+	for end < len(b) && (b[end] >= '0' && b[end] <= '9' || b[end] == ';') {
+		end++
+	}
+	if end == len(b) {
+		if end >= maxSeqLen {
+			return Mouse{}, end, false
+		}
+		return Mouse{}, 0, false
+	}
+	if b[end] != 'M' && b[end] != 'm' {
+		return Mouse{}, end, false
+	}
+
+	m, ok := decodeSGRMouse(parseParams(string(b[3:end])), b[end])
+	return m, end + 1, ok
+}
+
+// decodeKey decodes the first key of the input and returns how many bytes it took.
+// Zero length means the input is an incomplete sequence. ok is false for recognized but unsupported sequences.
+func decodeKey(b []byte) (key Key, n int, ok bool) {
+	switch c := b[0]; {
+	case c == '\033':
+		return decodeEscape(b)
+	case c == '\r' || c == '\n':
+		return Key{Kind: Enter}, 1, true
+	case c == '\t':
+		return Key{Kind: Tab}, 1, true
+	case c == 0x7f || c == 0x08:
+		return Key{Kind: Backspace}, 1, true
+	case c == 0:
+		return Key{Kind: Letter, Char: ' ', Mod: ModCtrl}, 1, true
+	case c <= 0x1a:
+		return Key{Kind: Letter, Char: rune('a' + c - 1), Mod: ModCtrl}, 1, true
+	case c < 0x20:
+		return Key{}, 1, false
+	case c < utf8.RuneSelf:
+		return Key{Kind: Letter, Char: rune(c)}, 1, true
+	}
+
+	if !utf8.FullRune(b) {
+		return Key{}, 0, false
+	}
+	r, size := utf8.DecodeRune(b)
+	if r == utf8.RuneError {
+		return Key{}, size, false
+	}
+	return Key{Kind: Letter, Char: r}, size, true
+}
+
+func decodeEscape(b []byte) (Key, int, bool) {
+	if len(b) == 1 {
+		return Key{}, 0, false
+	}
+
+	switch b[1] {
+	case '[':
+		return decodeCSI(b)
+	case 'O':
+		if len(b) < 3 {
+			return Key{}, 0, false
+		}
+		kind, ok := ss3Keys[b[2]]
+		return Key{Kind: kind}, 3, ok
+	case '\033':
+		return Key{Kind: Esc, Mod: ModAlt}, 2, true
+	}
+
+	// Esc followed by a key is how terminals send Alt+key
+	key, n, ok := decodeKey(b[1:])
+	if n == 0 {
+		return Key{}, 0, false
+	}
+	key.Mod |= ModAlt
+	return key, n + 1, ok
+}
+
+var ss3Keys = map[byte]KeyKind{
+	'A': Up,
+	'B': Down,
+	'C': Right,
+	'D': Left,
+	'H': Home,
+	'F': End,
+	'P': F1,
+	'Q': F2,
+	'R': F3,
+	'S': F4,
+}
+
+var csiTildeKeys = map[int]KeyKind{
+	1:  Home,
+	2:  Insert,
+	3:  Delete,
+	4:  End,
+	5:  PageUp,
+	6:  PageDown,
+	7:  Home,
+	8:  End,
+	11: F1,
+	12: F2,
+	13: F3,
+	14: F4,
+	15: F5,
+	17: F6,
+	18: F7,
+	19: F8,
+	20: F9,
+	21: F10,
+	23: F11,
+	24: F12,
+}
+
+// decodeCSI decodes "\033[{params}{final}" sequences, e.g. "\033[1;5C" for Ctrl+Right or "\033[15~" for F5.
+func decodeCSI(b []byte) (Key, int, bool) {
+	end := 2
+	for end < len(b) && b[end] >= 0x20 && b[end] <= 0x3f {
+		end++
+	}
+	if end == len(b) {
+		if end >= maxSeqLen {
+			return Key{}, end, false
+		}
+		return Key{}, 0, false
+	}
+
+	n := end + 1
+	final := b[end]
+	if final < 0x40 || final > 0x7e {
+		return Key{}, end, false // malformed, drop everything before the offending byte
+	}
+
+	raw := string(b[2:end])
+	if flags, ok := strings.CutPrefix(raw, "?"); ok && final == 'u' {
+		v, err := strconv.Atoi(flags)
+		return Key{Kind: keyboardFlags, Char: rune(v)}, n, err == nil
+	}
+
+	params := parseParams(raw)
+	mod := xtermModifier(params.get(1, 0))
+	action := keyAction(params.get(1, 1))
+
+	switch final {
+	case 'u':
+		key, ok := decodeKittyKey(params)
+		return key, n, ok
+	case '~':
+		kind, ok := csiTildeKeys[params.get(0, 0)]
+		return Key{Kind: kind, Mod: mod, Action: action}, n, ok
+	}
+
+	kind, ok := ss3Keys[final]
+	return Key{Kind: kind, Mod: mod, Action: action}, n, ok
+}
+
+// csiParams holds the params of a control sequence with their colon separated sub-params, "1;5:3" is [[1] [5 3]].
+type csiParams [][]int
+
+// parseParams parses "1;5:3" into [[1] [5 3]], empty and malformed params become zeroes.
+func parseParams(s string) csiParams {
+	if s == "" {
+		return nil
+	}
+
+	fields := strings.Split(s, ";")
+	params := make(csiParams, len(fields))
+	for i, f := range fields {
+		subs := strings.Split(f, ":")
+		params[i] = make([]int, len(subs))
+		for j, sub := range subs {
+			params[i][j], _ = strconv.Atoi(sub)
+		}
+	}
+	return params
+}
+
+// get returns the sub-param, zero if it's absent.
+func (p csiParams) get(i, sub int) int {
+	if i >= len(p) || sub >= len(p[i]) {
+		return 0
+	}
+	return p[i][sub]
+}
+
+// xtermModifier converts the xterm modifier param (1 + bitmask of shift=1, alt=2, ctrl=4) to Modifier.
+func xtermModifier(param int) Modifier {
+	if param < 1 {
+		return 0
+	}
+	bits := param - 1
+	mod := Modifier(0)
+	if bits&1 != 0 {
+		mod |= ModShift
+	}
+	if bits&2 != 0 {
+		mod |= ModAlt
+	}
+	if bits&4 != 0 {
+		mod |= ModCtrl
+	}
+	return mod
+}
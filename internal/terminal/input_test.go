@@ -0,0 +1,128 @@
+package terminal
+
+import (
+	"context"
+	"io"
+	"slices"
+	"testing"
+)
+
+func TestDecodeKeys(t *testing.T) {
+	cases := []struct {
+		input    string
+		expected []Key
+	}{
+		{"q", []Key{{Kind: Letter, Char: 'q'}}},
+		{"ab", []Key{{Kind: Letter, Char: 'a'}, {Kind: Letter, Char: 'b'}}},
+		{"\033[A\033[B", []Key{{Kind: Up}, {Kind: Down}}},
+		{"\033OD", []Key{{Kind: Left}}},
+		{"\033[1;5C", []Key{{Kind: Right, Mod: ModCtrl}}},
+		{"\033[1;2H", []Key{{Kind: Home, Mod: ModShift}}},
+		{"\033[4~\033[5~\033[6~", []Key{{Kind: End}, {Kind: PageUp}, {Kind: PageDown}}},
+		{"\033[15~\033OP\033[24;3~", []Key{{Kind: F5}, {Kind: F1}, {Kind: F12, Mod: ModAlt}}},
+		{"\033x", []Key{{Kind: Letter, Char: 'x', Mod: ModAlt}}},
+		{"\x01\r\x7f", []Key{{Kind: Letter, Char: 'a', Mod: ModCtrl}, {Kind: Enter}, {Kind: Backspace}}},
+		{"é ж", []Key{{Kind: Letter, Char: 'é'}, {Kind: Letter, Char: ' '}, {Kind: Letter, Char: 'ж'}}},
+		{"\033[99Zq", []Key{{Kind: Letter, Char: 'q'}}},
+	}
+
+	for _, c := range cases {
+		var dec keyDecoder
+		actual := keysOf(dec.Feed([]byte(c.input)))
+		if !slices.Equal(c.expected, actual) {
+			t.Errorf("input %q: expected: %v got: %v", c.input, c.expected, actual)
+		}
+		eq(t, false, dec.Pending())
+	}
+}
+
+func TestDecodeSplitSequence(t *testing.T) {
+	var dec keyDecoder
+
+	eq(t, 0, len(dec.Feed([]byte("\033"))))
+	eq(t, 0, len(dec.Feed([]byte("[1;"))))
+	eq(t, true, dec.Pending())
+
+	keys := keysOf(dec.Feed([]byte("5Dq\xd0")))
+	eq(t, 2, len(keys))
+	eq(t, Key{Kind: Left, Mod: ModCtrl}, keys[0])
+	eq(t, Key{Kind: Letter, Char: 'q'}, keys[1])
+
+	keys = keysOf(dec.Feed([]byte("\xb6")))
+	eq(t, Key{Kind: Letter, Char: 'ж'}, keys[0])
+}
+
+func TestFlushReportsLoneEsc(t *testing.T) {
+	var dec keyDecoder
+
+	eq(t, 0, len(dec.Feed([]byte("\033"))))
+	keys := keysOf(dec.Flush())
+	eq(t, 1, len(keys))
+	eq(t, Key{Kind: Esc}, keys[0])
+	eq(t, false, dec.Pending())
+
+	dec.Feed([]byte("\033["))
+	keys = keysOf(dec.Flush())
+	eq(t, 2, len(keys))
+	eq(t, Key{Kind: Esc}, keys[0])
+	eq(t, Key{Kind: Letter, Char: '['}, keys[1])
+}
+
+func TestKeyString(t *testing.T) {
+	eq(t, "ctrl+shift+left", Key{Kind: Left, Mod: ModCtrl | ModShift}.String())
+	eq(t, "space", Key{Kind: Letter, Char: ' '}.String())
+	eq(t, "alt+q", Key{Kind: Letter, Char: 'q', Mod: ModAlt}.String())
+}
+
+func TestWatchKeystrokesReportsLoneEscAfterTimeout(t *testing.T) {
+	stdin, stdinWriter := io.Pipe()
+	defer stdinWriter.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	term := NewTerminal(stdin, io.Discard, nil)
+	events, _ := term.WatchInput(ctx)
+
+	stdinWriter.Write([]byte("\033"))
+	eq(t, Event(Key{Kind: Esc}), <-events)
+
+	stdinWriter.Write([]byte("\033[A\033"))
+	stdinWriter.Write([]byte("[B"))
+	eq(t, Event(Key{Kind: Up}), <-events)
+	eq(t, Event(Key{Kind: Down}), <-events)
+}
+
+func TestParseKey(t *testing.T) {
+	for _, k := range []Key{
+		{Kind: Left, Mod: ModCtrl | ModShift},
+		{Kind: Letter, Char: ' '},
+		{Kind: Letter, Char: '+', Mod: ModAlt},
+		{Kind: Letter, Char: 'ж'},
+		{Kind: F11},
+	} {
+		parsed, err := ParseKey(k.String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		eq(t, k, parsed)
+	}
+
+	parsed, err := ParseKey("Ctrl+PgUp")
+	eq(t, nil, err)
+	eq(t, Key{Kind: PageUp, Mod: ModCtrl}, parsed)
+
+	for _, bad := range []string{"", "meta+a", "letter", "lft"} {
+		if _, err := ParseKey(bad); err == nil {
+			t.Errorf("expected error for %q", bad)
+		}
+	}
+}
+
+func keysOf(events []Event) []Key {
+	var keys []Key
+	for _, e := range events {
+		keys = append(keys, e.(Key))
+	}
+	return keys
+}
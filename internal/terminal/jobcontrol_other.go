@@ -0,0 +1,14 @@
+//go:build !linux && !darwin
+
+package terminal
+
+import (
+	"errors"
+	"os"
+)
+
+func notifySuspend(suspend, cont chan<- os.Signal) {}
+
+func StopProcess() error {
+	return errors.New("job control is not supported on this platform")
+}
@@ -0,0 +1,19 @@
+//go:build linux || darwin
+
+package terminal
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+func notifySuspend(suspend, cont chan<- os.Signal) {
+	signal.Notify(suspend, syscall.SIGTSTP)
+	signal.Notify(cont, syscall.SIGCONT)
+}
+
+// StopProcess stops the process the same way the default SIGTSTP handler does, the execution continues on SIGCONT.
+func StopProcess() error {
+	return syscall.Kill(syscall.Getpid(), syscall.SIGSTOP)
+}
@@ -0,0 +1,82 @@
+package terminal
+
+import (
+	"fmt"
+)
+
+// Flags of the kitty progressive keyboard enhancement, see https://sw.kovidgoyal.net/kitty/keyboard-protocol/
+const (
+	kittyDisambiguate     = 1
+	kittyReportEventTypes = 2
+)
+
+// EnableKeyboardProtocol asks the terminal to report key repeats and releases with the kitty keyboard protocol
+// and queries whether it's supported. Terminals without the protocol ignore both sequences and keep sending legacy keys.
+func (t *Terminal) EnableKeyboardProtocol() {
+	fmt.Fprintf(t.out(), "\033[>%du\033[?u", kittyDisambiguate|kittyReportEventTypes)
+}
+
+// DisableKeyboardProtocol restores the keyboard mode which was active before EnableKeyboardProtocol.
+func (t *Terminal) DisableKeyboardProtocol() {
+	fmt.Fprint(t.out(), "\033[<u")
+}
+
+// ReportsKeyReleases tells whether the terminal confirmed it sends key release events.
+func (t *Terminal) ReportsKeyReleases() bool {
+	return t.keyFlags.Load()&kittyReportEventTypes != 0
+}
+
+var kittyKeys = map[int]KeyKind{
+	9:     Tab,
+	13:    Enter,
+	27:    Esc,
+	127:   Backspace,
+	57348: Insert,
+	57349: Delete,
+	57350: Left,
+	57351: Right,
+	57352: Up,
+	57353: Down,
+	57354: PageUp,
+	57355: PageDown,
+	57356: Home,
+	57357: End,
+}
+
+// decodeKittyKey decodes params of "\033[{code}[:{shifted}];{modifiers}[:{event}]u".
+func decodeKittyKey(params csiParams) (Key, bool) {
+	code := params.get(0, 0)
+	key := Key{
+		Mod:    xtermModifier(params.get(1, 0)),
+		Action: keyAction(params.get(1, 1)),
+	}
+
+	if kind, ok := kittyKeys[code]; ok {
+		key.Kind = kind
+		return key, true
+	}
+
+	if code < 0x20 || code >= 57344 { // control codes and private use area of functional keys
+		return Key{}, false
+	}
+
+	key.Kind = Letter
+	key.Char = rune(code)
+	if shifted := params.get(0, 1); shifted != 0 && key.Mod&ModShift != 0 {
+		key.Char = rune(shifted) // the same as legacy terminals send: "A" instead of "shift+a"
+		key.Mod &^= ModShift
+	}
+
+	return key, true
+}
+
+// keyAction converts the event type sub-param: 1 or absent is press, 2 is repeat, 3 is release.
+func keyAction(event int) KeyAction {
+	switch event {
+	case 2:
+		return Repeat
+	case 3:
+		return Release
+	}
+	return Press
+}
@@ -0,0 +1,78 @@
+package terminal
+
+import (
+	"context"
+	"io"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestDecodeKittyKeys(t *testing.T) {
+	cases := []struct {
+		input    string
+		expected []Key
+	}{
+		{"\033[97u", []Key{{Kind: Letter, Char: 'a'}}},
+		{"\033[97;1:2u", []Key{{Kind: Letter, Char: 'a', Action: Repeat}}},
+		{"\033[97;5:3u", []Key{{Kind: Letter, Char: 'a', Mod: ModCtrl, Action: Release}}},
+		{"\033[97:65;2u", []Key{{Kind: Letter, Char: 'A'}}},
+		{"\033[32;1:3u", []Key{{Kind: Letter, Char: ' ', Action: Release}}},
+		{"\033[27u\033[13;3u", []Key{{Kind: Esc}, {Kind: Enter, Mod: ModAlt}}},
+		{"\033[1;1:3D\033[1;5:2A", []Key{{Kind: Left, Action: Release}, {Kind: Up, Mod: ModCtrl, Action: Repeat}}},
+		{"\033[3;1:3~", []Key{{Kind: Delete, Action: Release}}},
+		{"\033[57441u", nil}, // left shift alone
+		{"\033[99;5u\033[122;5u", []Key{{Kind: Letter, Char: 'c', Mod: ModCtrl}, {Kind: Letter, Char: 'z', Mod: ModCtrl}}},
+	}
+
+	for _, c := range cases {
+		var dec keyDecoder
+		actual := keysOf(dec.Feed([]byte(c.input)))
+		if !slices.Equal(c.expected, actual) {
+			t.Errorf("input %q: expected: %v got: %v", c.input, c.expected, actual)
+		}
+	}
+}
+
+func TestKeyboardProtocolNegotiation(t *testing.T) {
+	stdin, stdinWriter := io.Pipe()
+	defer stdinWriter.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	out := &recordingWriter{}
+	term := NewTerminal(stdin, out, nil)
+	term.EnableKeyboardProtocol()
+	eq(t, "\033[>3u\033[?u", out.writes[0])
+	eq(t, false, term.ReportsKeyReleases())
+
+	events, _ := term.WatchInput(ctx)
+	stdinWriter.Write([]byte("\033[?3u\033[1;1:3C"))
+	eq(t, Event(Key{Kind: Right, Action: Release}), <-events)
+	eq(t, true, term.ReportsKeyReleases())
+
+	term.DisableKeyboardProtocol()
+	eq(t, "\033[<u", out.writes[1])
+}
+
+func TestLegacyTerminalIgnoresKeyboardProtocol(t *testing.T) {
+	stdin, stdinWriter := io.Pipe()
+	defer stdinWriter.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	term := NewTerminal(stdin, io.Discard, nil)
+	term.EnableKeyboardProtocol()
+	events, _ := term.WatchInput(ctx)
+
+	stdinWriter.Write([]byte("\033[C"))
+	select {
+	case e := <-events:
+		eq(t, Event(Key{Kind: Right}), e)
+	case <-time.After(time.Second):
+		t.Fatal("key wasn't decoded")
+	}
+	eq(t, false, term.ReportsKeyReleases())
+}
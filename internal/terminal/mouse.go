@@ -0,0 +1,94 @@
+package terminal
+
+import (
+	"fmt"
+)
+
+// Event is an input event, either Key or Mouse.
+type Event interface {
+	IsEvent()
+}
+
+func (k Key) IsEvent() {}
+
+type MouseButton uint8
+
+const (
+	MouseLeft MouseButton = iota
+	MouseMiddle
+	MouseRight
+	MouseNone // motion without a pressed button
+	WheelUp
+	WheelDown
+)
+
+type MouseAction uint8
+
+const (
+	MousePress MouseAction = iota
+	MouseRelease
+	MouseDrag
+	MouseWheel
+)
+
+// Mouse is a mouse event, Line and Col are 1-based like in SetCursor.
+type Mouse struct {
+	Line   int
+	Col    int
+	Button MouseButton
+	Action MouseAction
+	Mod    Modifier
+}
+
+func (m Mouse) IsEvent() {}
+
+// EnableMouse turns on reporting of presses, releases, drags and wheel in SGR extended format.
+func (t *Terminal) EnableMouse() {
+	fmt.Fprint(t.out(), "\033[?1000h\033[?1002h\033[?1006h")
+}
+
+func (t *Terminal) DisableMouse() {
+	fmt.Fprint(t.out(), "\033[?1006l\033[?1002l\033[?1000l")
+}
+
+// decodeSGRMouse decodes params of "\033[<{button};{col};{line}M", final 'm' means release.
+func decodeSGRMouse(params csiParams, final byte) (Mouse, bool) {
+	if len(params) != 3 {
+		return Mouse{}, false
+	}
+
+	b := params.get(0, 0)
+	m := Mouse{
+		Col:  params.get(1, 0),
+		Line: params.get(2, 0),
+	}
+
+	if b&4 != 0 {
+		m.Mod |= ModShift
+	}
+	if b&8 != 0 {
+		m.Mod |= ModAlt
+	}
+	if b&16 != 0 {
+		m.Mod |= ModCtrl
+	}
+
+	switch {
+	case b&64 != 0:
+		m.Action = MouseWheel
+		m.Button = WheelUp
+		if b&1 != 0 {
+			m.Button = WheelDown
+		}
+		return m, b&2 == 0 // horizontal wheel isn't supported
+	case b&32 != 0:
+		m.Action = MouseDrag
+	case final == 'm':
+		m.Action = MouseRelease
+	default:
+		m.Action = MousePress
+	}
+	m.Button = MouseButton(b & 3)
+
+	return m, true
+}
@@ -0,0 +1,40 @@
+package terminal
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestDecodeMouse(t *testing.T) {
+	cases := []struct {
+		input    string
+		expected []Event
+	}{
+		{"\033[<0;12;5M", []Event{Mouse{Line: 5, Col: 12, Button: MouseLeft, Action: MousePress}}},
+		{"\033[<2;1;1m", []Event{Mouse{Line: 1, Col: 1, Button: MouseRight, Action: MouseRelease}}},
+		{"\033[<32;3;4M", []Event{Mouse{Line: 4, Col: 3, Button: MouseLeft, Action: MouseDrag}}},
+		{"\033[<35;3;4M", []Event{Mouse{Line: 4, Col: 3, Button: MouseNone, Action: MouseDrag}}},
+		{"\033[<65;7;8M", []Event{Mouse{Line: 8, Col: 7, Button: WheelDown, Action: MouseWheel}}},
+		{"\033[<16;2;2Mq", []Event{Mouse{Line: 2, Col: 2, Mod: ModCtrl}, Key{Kind: Letter, Char: 'q'}}},
+		{"\033[<66;1;1M\033[<1;2Mx", []Event{Key{Kind: Letter, Char: 'x'}}},
+	}
+
+	for _, c := range cases {
+		var dec keyDecoder
+		actual := dec.Feed([]byte(c.input))
+		if !slices.Equal(c.expected, actual) {
+			t.Errorf("input %q: expected: %v got: %v", c.input, c.expected, actual)
+		}
+	}
+}
+
+func TestDecodeSplitMouse(t *testing.T) {
+	var dec keyDecoder
+
+	eq(t, 0, len(dec.Feed([]byte("\033[<0;1"))))
+	eq(t, true, dec.Pending())
+
+	events := dec.Feed([]byte("0;20M"))
+	eq(t, 1, len(events))
+	eq(t, Event(Mouse{Line: 20, Col: 10}), events[0])
+}
@@ -0,0 +1,13 @@
+//go:build !linux && !darwin
+
+package terminal
+
+import "errors"
+
+type pollReader struct {
+	cancelReader
+}
+
+func newPollReader(fd int) (*pollReader, error) {
+	return nil, errors.New("poll reader is not supported on this platform")
+}
@@ -0,0 +1,101 @@
+//go:build linux || darwin
+
+package terminal
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sync/atomic"
+	"syscall"
+	"unsafe"
+)
+
+// pollReader waits for the input with select on the fd together with a wake-up pipe.
+// Cancel writes to the pipe, so the blocked select returns without consuming the input.
+type pollReader struct {
+	fd       int
+	wakeR    int
+	wakeW    int
+	canceled atomic.Bool
+}
+
+func newPollReader(fd int) (*pollReader, error) {
+	if fd >= fdSetSize {
+		return nil, fmt.Errorf("fd %d doesn't fit select", fd)
+	}
+
+	var wake [2]int
+	if err := syscall.Pipe(wake[:]); err != nil {
+		return nil, fmt.Errorf("create wake-up pipe: %w", err)
+	}
+	if wake[0] >= fdSetSize {
+		syscall.Close(wake[0])
+		syscall.Close(wake[1])
+		return nil, fmt.Errorf("fd %d doesn't fit select", wake[0])
+	}
+
+	return &pollReader{
+		fd:    fd,
+		wakeR: wake[0],
+		wakeW: wake[1],
+	}, nil
+}
+
+func (r *pollReader) Read(p []byte) (int, error) {
+	for {
+		if r.canceled.Load() {
+			return 0, errCanceled
+		}
+
+		var fds syscall.FdSet
+		fdSet(&fds, r.fd)
+		fdSet(&fds, r.wakeR)
+		if err := sysSelect(max(r.fd, r.wakeR)+1, &fds); err != nil {
+			if errors.Is(err, syscall.EINTR) {
+				continue
+			}
+			return 0, fmt.Errorf("select: %w", err)
+		}
+
+		if fdIsSet(&fds, r.wakeR) {
+			return 0, errCanceled
+		}
+		if !fdIsSet(&fds, r.fd) {
+			continue
+		}
+
+		n, err := syscall.Read(r.fd, p)
+		if errors.Is(err, syscall.EINTR) || errors.Is(err, syscall.EAGAIN) {
+			continue
+		}
+		if err != nil {
+			return 0, err
+		}
+		if n == 0 {
+			return 0, io.EOF
+		}
+		return n, nil
+	}
+}
+
+func (r *pollReader) Cancel() {
+	r.canceled.Store(true)
+	syscall.Write(r.wakeW, []byte{0})
+}
+
+func (r *pollReader) Close() error {
+	return errors.Join(syscall.Close(r.wakeR), syscall.Close(r.wakeW))
+}
+
+var fdBits = int(8 * unsafe.Sizeof(syscall.FdSet{}.Bits[0]))
+
+var fdSetSize = fdBits * len(syscall.FdSet{}.Bits)
+
+func fdSet(s *syscall.FdSet, fd int) {
+	s.Bits[fd/fdBits] |= 1 << (fd % fdBits)
+}
+
+func fdIsSet(s *syscall.FdSet, fd int) bool {
+	return s.Bits[fd/fdBits]&(1<<(fd%fdBits)) != 0
+}
@@ -0,0 +1,7 @@
+package terminal
+
+import "syscall"
+
+func sysSelect(nfd int, r *syscall.FdSet) error {
+	return syscall.Select(nfd, r, nil, nil, nil)
+}
@@ -0,0 +1,8 @@
+package terminal
+
+import "syscall"
+
+func sysSelect(nfd int, r *syscall.FdSet) error {
+	_, err := syscall.Select(nfd, r, nil, nil, nil)
+	return err
+}
@@ -1,128 +1,378 @@
 package terminal
 
 import (
+	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"io"
+	"log/slog"
+	"os"
+	"os/signal"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"time"
 )
 
-type KeyKind uint8
+// ErrNotTerminal is returned when the input isn't attached to a terminal device, e.g. it's redirected from a file.
+var ErrNotTerminal = errors.New("stdin is not a terminal, run the game in an interactive terminal")
 
-const (
-	Left KeyKind = iota
-	Right
-	Up
-	Letter
-)
-
-var kkNames = map[KeyKind]string{
-	Left:   "left",
-	Right:  "right",
-	Up:     "up",
-	Letter: "letter",
-}
-
-func (kk KeyKind) String() string {
-	return kkNames[kk]
+// Mode switches the input device between the raw mode used by the game and its original settings.
+type Mode interface {
+	MakeRaw() error
+	Restore() error
 }
 
-type Key struct {
-	Kind KeyKind
-	Char byte
+// Window is a terminal on the other end of a connection, e.g. a telnet session, passed as the stdout.
+// It reports its size and resizes itself, and the job control signals of the process don't concern it.
+type Window interface {
+	Size() (lines, cols int, err error)
+	Resized() <-chan struct{}
 }
 
 type Terminal struct {
-	stdin  io.Reader
-	stdout io.Writer
-	exec   func(cmd string, args ...string) error
+	stdin      io.Reader
+	stdout     io.Writer
+	mode       Mode
+	frame      bytes.Buffer
+	frameDepth int
+	syncOutput bool
+	keyFlags   atomic.Int32
+	logger     *slog.Logger
 }
 
 func NewTerminal(
 	stdin io.Reader,
 	stdout io.Writer,
-	exec func(cmd string, args ...string) error,
+	mode Mode,
 ) *Terminal {
 	return &Terminal{
 		stdin:  stdin,
 		stdout: stdout,
-		exec:   exec,
+		mode:   mode,
+		logger: slog.New(slog.NewTextHandler(io.Discard, nil)),
+	}
+}
+
+// SetLogger sends the decoded input to the logger, it's discarded by default.
+// Call it before WatchInput.
+func (t *Terminal) SetLogger(l *slog.Logger) {
+	t.logger = l
+}
+
+// SetSyncOutput enables wrapping of every frame into DEC synchronized output mode (CSI ?2026h/l),
+// so the terminal presents the whole frame at once instead of painting it write by write.
+func (t *Terminal) SetSyncOutput(enabled bool) {
+	t.syncOutput = enabled
+}
+
+// BeginFrame starts buffering of the output until the matching EndFrame call.
+// Frames can be nested, only the outermost EndFrame flushes the buffer.
+func (t *Terminal) BeginFrame() {
+	t.frameDepth++
+}
+
+// EndFrame flushes everything printed since BeginFrame in a single write.
+func (t *Terminal) EndFrame() {
+	if t.frameDepth == 0 {
+		return
+	}
+	t.frameDepth--
+	if t.frameDepth > 0 || t.frame.Len() == 0 {
+		return
+	}
+
+	if t.syncOutput {
+		out := make([]byte, 0, t.frame.Len()+16)
+		out = append(out, "\033[?2026h"...)
+		out = append(out, t.frame.Bytes()...)
+		out = append(out, "\033[?2026l"...)
+		t.stdout.Write(out)
+	} else {
+		t.stdout.Write(t.frame.Bytes())
+	}
+	t.frame.Reset()
+}
+
+// FlushFrames closes all open frames and writes out their content.
+// Used on teardown, when frames might be left open by an interrupted drawing.
+func (t *Terminal) FlushFrames() {
+	for t.frameDepth > 0 {
+		t.EndFrame()
+	}
+}
+
+func (t *Terminal) out() io.Writer {
+	if t.frameDepth > 0 {
+		return &t.frame
 	}
+	return t.stdout
 }
 
 func (t *Terminal) Print(s string) {
-	fmt.Fprint(t.stdout, s)
+	fmt.Fprint(t.out(), s)
 }
 
 func (t *Terminal) Println(s string) {
-	fmt.Fprintf(t.stdout, "%s\n", s)
+	fmt.Fprintf(t.out(), "%s\n", s)
 }
 
 func (t *Terminal) Printf(format string, a ...any) {
-	fmt.Fprintf(t.stdout, format, a...)
+	fmt.Fprintf(t.out(), format, a...)
 }
 
 func (t *Terminal) Clear() {
-	fmt.Fprint(t.stdout, "\033[H\033[2J")
+	fmt.Fprint(t.out(), "\033[H\033[2J")
 }
 
-func (t *Terminal) WatchKeystrokes(ctx context.Context) (<-chan Key, <-chan error) {
-	keys, errc := make(chan Key), make(chan error, 1)
-	buf := make([]byte, 3)
-
-	keyMap := map[string]KeyKind{
-		"\033[A": Up,
-		"\033[C": Right,
-		"\033[D": Left,
-	}
+// WatchInput decodes the input into key and mouse events until the ctx is done or reading fails.
+// The channels are closed only after reading has stopped, so waiting for it guarantees that
+// no keystroke typed after the game is over gets consumed.
+func (t *Terminal) WatchInput(ctx context.Context) (<-chan Event, <-chan error) {
+	events, errc := make(chan Event), make(chan error, 1)
+	r, cancellable := newCancelReader(t.stdin)
+	chunks, readErr, readDone := readChunks(ctx, r)
 
 	go func() {
 		defer close(errc)
-		defer close(keys)
+		defer close(events)
+		defer func() {
+			r.Cancel()
+			if cancellable {
+				<-readDone
+				r.Close()
+			}
+		}()
 
-		for ctx.Err() == nil {
-			n, err := t.stdin.Read(buf)
-			if err != nil {
+		var dec keyDecoder
+		escTimer := time.NewTimer(escTimeout)
+		escTimer.Stop()
+		defer escTimer.Stop()
+
+		send := func(decoded []Event) bool {
+			for _, e := range decoded {
+				if k, ok := e.(Key); ok && k.Kind == keyboardFlags {
+					t.logger.Info("keyboard protocol", "flags", int(k.Char))
+					t.keyFlags.Store(int32(k.Char))
+					continue
+				}
+				t.logger.Debug("input", "event", fmt.Sprint(e))
+				select {
+				case events <- e:
+				case <-ctx.Done():
+					return false
+				}
+			}
+			return true
+		}
+
+		for {
+			select {
+			case chunk := <-chunks:
+				escTimer.Stop()
+				if !send(dec.Feed(chunk)) {
+					return
+				}
+				if dec.Pending() {
+					escTimer.Reset(escTimeout)
+				}
+			case <-escTimer.C:
+				if !send(dec.Flush()) {
+					return
+				}
+			case err := <-readErr:
 				errc <- fmt.Errorf("read stdin: %w", err)
-				break
+				return
+			case <-ctx.Done():
+				return
 			}
+		}
+	}()
+
+	return events, errc
+}
+
+// readChunks reads in the background, so the decoder can wait for input and timeouts at the same time.
+func readChunks(ctx context.Context, r io.Reader) (<-chan []byte, <-chan error, <-chan struct{}) {
+	chunks, errc, done := make(chan []byte), make(chan error, 1), make(chan struct{})
+
+	go func() {
+		defer close(done)
 
-			if n == 1 {
-				keys <- Key{
-					Kind: Letter,
-					Char: buf[0],
+		buf := make([]byte, 256)
+		for {
+			n, err := r.Read(buf)
+			if n > 0 {
+				select {
+				case chunks <- slices.Clone(buf[:n]):
+				case <-ctx.Done():
+					return
 				}
-				continue
 			}
+			if errors.Is(err, errCanceled) {
+				return
+			}
+			if err != nil {
+				errc <- err
+				return
+			}
+		}
+	}()
 
-			if kk, ok := keyMap[string(buf[:n])]; ok {
-				keys <- Key{Kind: kk}
+	return chunks, errc, done
+}
+
+// Size returns the window size of the terminal attached to the stdout.
+func (t *Terminal) Size() (lines, cols int, err error) {
+	if w, ok := t.stdout.(Window); ok {
+		return w.Size()
+	}
+	f, ok := t.stdout.(interface{ Fd() uintptr })
+	if !ok {
+		return 0, 0, errors.New("stdout is not a terminal")
+	}
+
+	return windowSize(f.Fd())
+}
+
+// WatchResize notifies about every change of the window size until the ctx is done.
+// Bursts of changes are coalesced into a single notification.
+func (t *Terminal) WatchResize(ctx context.Context) <-chan struct{} {
+	resized := make(chan struct{}, 1)
+	if w, ok := t.stdout.(Window); ok {
+		go func() {
+			for {
+				select {
+				case <-w.Resized():
+					notify(resized)
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+		return resized
+	}
+
+	sigc := make(chan os.Signal, 1)
+	notifyResize(sigc)
+
+	go func() {
+		defer signal.Stop(sigc)
+
+		for {
+			select {
+			case <-sigc:
+				notify(resized)
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return resized
+}
+
+// Remote tells whether the stdout is a Window, a terminal whose keys don't raise signals in this process.
+func (t *Terminal) Remote() bool {
+	_, ok := t.stdout.(Window)
+	return ok
+}
+
+// WatchSuspend notifies about Ctrl-Z (SIGTSTP) and about continuing after a stop (SIGCONT) until the ctx is done.
+// Catching SIGTSTP disables its default action, the receiver has to stop the process itself, e.g. with StopProcess.
+// A Remote terminal gets no notifications, the signals of this process aren't about it.
+func (t *Terminal) WatchSuspend(ctx context.Context) (suspend, cont <-chan struct{}) {
+	suspended, continued := make(chan struct{}, 1), make(chan struct{}, 1)
+	if t.Remote() {
+		return suspended, continued
+	}
+	tstp, sigcont := make(chan os.Signal, 1), make(chan os.Signal, 1)
+	notifySuspend(tstp, sigcont)
+
+	go func() {
+		defer signal.Stop(tstp)
+		defer signal.Stop(sigcont)
+
+		for {
+			select {
+			case <-tstp:
+				notify(suspended)
+			case <-sigcont:
+				notify(continued)
+			case <-ctx.Done():
+				return
 			}
 		}
 	}()
 
-	return keys, errc
+	return suspended, continued
+}
+
+func notify(c chan<- struct{}) {
+	select {
+	case c <- struct{}{}:
+	default:
+	}
 }
 
 // SetCursor send escape sequence to the stdout.
 // The line and column starts from 1 (not from 0).
 func (t *Terminal) SetCursor(line, column int) {
-	fmt.Fprintf(t.stdout, "\033[%d;%dH", line, column)
+	fmt.Fprintf(t.out(), "\033[%d;%dH", line, column)
+}
+
+func (t *Terminal) HideCursor() {
+	fmt.Fprint(t.out(), "\033[?25l")
+}
+
+func (t *Terminal) ShowCursor() {
+	fmt.Fprint(t.out(), "\033[?25h")
+}
+
+// EnterAltScreen switches to the alternate screen buffer, so the game doesn't pollute the scrollback.
+func (t *Terminal) EnterAltScreen() {
+	fmt.Fprint(t.out(), "\033[?1049h")
+}
+
+// ExitAltScreen switches back to the main screen buffer with the content it had before EnterAltScreen.
+func (t *Terminal) ExitAltScreen() {
+	fmt.Fprint(t.out(), "\033[?1049l")
 }
 
 func (t *Terminal) MoveCursorRight(n int) {
 	if n > 0 {
-		fmt.Fprintf(t.stdout, "\033[%dC", n)
+		fmt.Fprintf(t.out(), "\033[%dC", n)
 	}
 }
 
 func (t *Terminal) UseRawModeNoEcho() error {
-	// cbreak - disable input buffering, e.g. read characters immediately no waiting for Enter keystroke
-	// min 1 - Read returns after at least 1 character is available
-	// -echo - prevent print typed chars on the screen
-	err := t.exec("stty", "-f", "/dev/tty", "cbreak", "min", "1", "-echo")
-	if err != nil {
+	if err := t.mode.MakeRaw(); err != nil {
 		return fmt.Errorf("disable input buffering and echo: %w", err)
 	}
 
 	return nil
 }
+
+// RestoreMode puts the input device back into the state it had before UseRawModeNoEcho.
+func (t *Terminal) RestoreMode() error {
+	return t.mode.Restore()
+}
+
+// SupportsSyncOutput guesses by the environment whether the terminal understands synchronized output mode.
+// Terminals ignore unknown private modes, so a wrong guess costs only a few bytes per frame.
+func SupportsSyncOutput(getenv func(key string) string) bool {
+	programs := []string{"iTerm.app", "WezTerm", "ghostty", "vscode", "contour", "rio", "tmux"}
+	if slices.Contains(programs, getenv("TERM_PROGRAM")) {
+		return true
+	}
+
+	term := getenv("TERM")
+	for _, name := range []string{"kitty", "alacritty", "foot", "wezterm", "ghostty", "contour", "tmux"} {
+		if strings.Contains(term, name) {
+			return true
+		}
+	}
+
+	return getenv("KITTY_WINDOW_ID") != "" || getenv("WT_SESSION") != ""
+}
@@ -0,0 +1,105 @@
+package terminal
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+	"time"
+)
+
+type recordingWriter struct {
+	writes []string
+}
+
+func (w *recordingWriter) Write(p []byte) (int, error) {
+	w.writes = append(w.writes, string(p))
+	return len(p), nil
+}
+
+func TestFrameFlushesInSingleWrite(t *testing.T) {
+	out := &recordingWriter{}
+	term := NewTerminal(nil, out, nil)
+
+	term.BeginFrame()
+	term.SetCursor(1, 1)
+	term.Print("[]")
+	term.BeginFrame()
+	term.Printf("%c%c", ' ', '.')
+	term.EndFrame()
+	eq(t, 0, len(out.writes))
+
+	term.EndFrame()
+	eq(t, 1, len(out.writes))
+	eq(t, "\033[1;1H[] .", out.writes[0])
+
+	term.Print("x")
+	eq(t, 2, len(out.writes))
+}
+
+func TestFrameWrappedIntoSyncOutputMode(t *testing.T) {
+	out := &recordingWriter{}
+	term := NewTerminal(nil, out, nil)
+	term.SetSyncOutput(true)
+
+	term.BeginFrame()
+	term.EndFrame()
+	eq(t, 0, len(out.writes))
+
+	term.BeginFrame()
+	term.Print("[]")
+	term.EndFrame()
+	eq(t, "\033[?2026h[]\033[?2026l", out.writes[0])
+}
+
+type remoteWindow struct {
+	recordingWriter
+	resized chan struct{}
+}
+
+func (w *remoteWindow) Size() (lines, cols int, err error) { return 30, 100, nil }
+
+func (w *remoteWindow) Resized() <-chan struct{} { return w.resized }
+
+func TestRemoteWindowReportsSizeAndResizes(t *testing.T) {
+	out := &remoteWindow{resized: make(chan struct{})}
+	term := NewTerminal(nil, out, nil)
+
+	lines, cols, err := term.Size()
+	eq(t, nil, err)
+	eq(t, 30, lines)
+	eq(t, 100, cols)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	resized := term.WatchResize(ctx)
+	out.resized <- struct{}{}
+	select {
+	case <-resized:
+	case <-time.After(time.Second):
+		t.Fatal("resize wasn't reported")
+	}
+}
+
+func TestRawModeRequiresTerminal(t *testing.T) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	defer w.Close()
+
+	term := NewTerminal(r, w, NewTermios(r))
+	err = term.UseRawModeNoEcho()
+	if !errors.Is(err, ErrNotTerminal) {
+		t.Fatalf("expected ErrNotTerminal, got: %v", err)
+	}
+	eq(t, nil, term.RestoreMode())
+}
+
+func eq[T comparable](t *testing.T, expected, actual T) {
+	t.Helper()
+	if expected != actual {
+		t.Fatalf("expected: %v got: %v", expected, actual)
+	}
+}
@@ -0,0 +1,22 @@
+//go:build !linux && !darwin
+
+package terminal
+
+import (
+	"errors"
+	"os"
+)
+
+type Termios struct{}
+
+func NewTermios(f *os.File) *Termios {
+	return &Termios{}
+}
+
+func (t *Termios) MakeRaw() error {
+	return errors.New("raw mode is not supported on this platform")
+}
+
+func (t *Termios) Restore() error {
+	return nil
+}
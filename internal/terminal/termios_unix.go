@@ -0,0 +1,72 @@
+//go:build linux || darwin
+
+package terminal
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// Termios switches a terminal device into cbreak mode via ioctls and keeps the original attributes to restore them later.
+type Termios struct {
+	fd    uintptr
+	saved *syscall.Termios
+}
+
+func NewTermios(f *os.File) *Termios {
+	return &Termios{fd: f.Fd()}
+}
+
+// MakeRaw disables line buffering and echo, but keeps signals generation, so Ctrl-C still works.
+func (t *Termios) MakeRaw() error {
+	var attrs syscall.Termios
+	if err := ioctlTermios(t.fd, ioctlGetTermios, &attrs); err != nil {
+		if errors.Is(err, syscall.ENOTTY) || errors.Is(err, syscall.ENODEV) {
+			return ErrNotTerminal
+		}
+		return fmt.Errorf("get terminal attributes: %w", err)
+	}
+
+	if t.saved == nil {
+		saved := attrs
+		t.saved = &saved
+	}
+
+	// ICANON - read characters immediately, no waiting for Enter keystroke
+	// ECHO - prevent print typed chars on the screen
+	// VMIN 1, VTIME 0 - Read returns after at least 1 character is available
+	attrs.Lflag &^= syscall.ICANON | syscall.ECHO
+	attrs.Cc[syscall.VMIN] = 1
+	attrs.Cc[syscall.VTIME] = 0
+
+	if err := ioctlTermios(t.fd, ioctlSetTermios, &attrs); err != nil {
+		return fmt.Errorf("set terminal attributes: %w", err)
+	}
+
+	return nil
+}
+
+// Restore brings back the attributes saved by the first MakeRaw call.
+func (t *Termios) Restore() error {
+	if t.saved == nil {
+		return nil
+	}
+
+	if err := ioctlTermios(t.fd, ioctlSetTermios, t.saved); err != nil {
+		return fmt.Errorf("restore terminal attributes: %w", err)
+	}
+	t.saved = nil
+
+	return nil
+}
+
+func ioctlTermios(fd uintptr, req uintptr, attrs *syscall.Termios) error {
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, fd, req, uintptr(unsafe.Pointer(attrs)))
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
@@ -0,0 +1,14 @@
+//go:build !linux && !darwin
+
+package terminal
+
+import (
+	"errors"
+	"os"
+)
+
+func windowSize(fd uintptr) (lines, cols int, err error) {
+	return 0, 0, errors.New("window size is not supported on this platform")
+}
+
+func notifyResize(c chan<- os.Signal) {}
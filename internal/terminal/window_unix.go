@@ -0,0 +1,29 @@
+//go:build linux || darwin
+
+package terminal
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"unsafe"
+)
+
+type winsize struct {
+	Row, Col, Xpixel, Ypixel uint16
+}
+
+func windowSize(fd uintptr) (lines, cols int, err error) {
+	var ws winsize
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, fd, syscall.TIOCGWINSZ, uintptr(unsafe.Pointer(&ws)))
+	if errno != 0 {
+		return 0, 0, fmt.Errorf("ioctl TIOCGWINSZ: %w", errno)
+	}
+
+	return int(ws.Row), int(ws.Col), nil
+}
+
+func notifyResize(c chan<- os.Signal) {
+	signal.Notify(c, syscall.SIGWINCH)
+}
@@ -1,7 +1,9 @@
 package tui
 
 import (
+	"fmt"
 	"strings"
+	"unicode/utf8"
 
 	"github.com/opennikish/tetris/internal/game"
 	"github.com/opennikish/tetris/internal/terminal"
@@ -11,6 +13,8 @@ const BorderOffset = 2
 
 type PlayfieldRenderer struct {
 	term    *terminal.Terminal
+	theme   Theme
+	color   ColorMode
 	offsetX int
 	offsetY int
 }
@@ -18,13 +22,36 @@ type PlayfieldRenderer struct {
 func NewPlayfieldRenderer(term *terminal.Terminal, offsetX, offsetY int) *PlayfieldRenderer {
 	return &PlayfieldRenderer{
 		term:    term,
+		theme:   Themes[DefaultTheme],
+		color:   ColorNone,
 		offsetX: offsetX,
 		offsetY: offsetY,
 	}
 }
 
+// SetOffset moves the origin of the board, takes effect on the next full Draw.
+func (r *PlayfieldRenderer) SetOffset(offsetX, offsetY int) {
+	r.offsetX = offsetX
+	r.offsetY = offsetY
+}
+
+// SetTheme changes the cell glyphs, takes effect on the next full Draw.
+func (r *PlayfieldRenderer) SetTheme(theme Theme) {
+	r.theme = theme
+}
+
+// SetColorMode changes the colours of blocks, ColorAuto is expected to be resolved with DetectColorMode.
+func (r *PlayfieldRenderer) SetColorMode(mode ColorMode) {
+	r.color = mode
+}
+
+// BoardSize returns how many lines and columns the drawn playfield occupies including borders.
+func BoardSize(playfield *game.Playfield) (lines, cols int) {
+	return playfield.Height() + 3, playfield.Width()*2 + BorderOffset*2
+}
+
+// Draw draws the board with its borders, the screen is expected to be cleared.
 func (r *PlayfieldRenderer) Draw(playfield *game.Playfield) {
-	r.term.Clear()
 	r.term.SetCursor(r.offsetY+1, r.offsetX+1)
 
 	leftBorder := "<!"
@@ -69,30 +96,206 @@ func (r *PlayfieldRenderer) RedrawPlayfieldLine(i int, line []game.CellKind) {
 }
 
 func (r *PlayfieldRenderer) DrawTetro(tetro *game.Tetromino, ck game.CellKind) {
+	r.drawPoints(tetro, func() { r.renderCell(ck) })
+}
+
+// DrawGhost marks where the tetromino lands, erase it with DrawTetro and game.CellEmpty.
+func (r *PlayfieldRenderer) DrawGhost(ghost *game.Tetromino) {
+	r.drawPoints(ghost, func() { r.colored(ghostColor[r.color], r.theme.Ghost) })
+}
+
+func (r *PlayfieldRenderer) drawPoints(tetro *game.Tetromino, draw func()) {
 	for _, p := range tetro.Points {
 		if p.Y < 1 {
 			continue // Prevent rendering above playfield on rotation
 		}
 		r.term.SetCursor(r.offsetY+p.Y+1, r.offsetX+BorderOffset+p.X*2+1)
-		r.renderCell(ck)
+		draw()
 	}
 }
 
-// todo: accept mapper func in struct
+func (r *PlayfieldRenderer) colored(color, glyph string) {
+	if color == "" {
+		r.term.Print(glyph)
+		return
+	}
+	r.term.Print(color + glyph + resetColor)
+}
+
 func (r *PlayfieldRenderer) renderCell(ck game.CellKind) {
 	switch ck {
 	case game.CellBlock:
-		r.term.Printf("%c%c", '[', ']')
+		r.colored(blockColor[r.color], r.theme.Block)
 	case game.CellEmpty:
-		r.term.Printf("%c%c", ' ', '.')
+		r.term.Print(r.theme.Empty)
 	case game.CellHidden:
-		r.term.Printf("%c%c", ' ', ' ')
+		r.term.Print(r.theme.Hidden)
 	default:
-		r.term.Printf("%c%c", '?', '?')
+		r.term.Print("??")
 	}
 }
 
+// CellAt maps the terminal position (1-based, as in mouse events) to the playfield cell under it.
+func (r *PlayfieldRenderer) CellAt(playfield *game.Playfield, line, col int) (i, j int, ok bool) {
+	i = line - r.offsetY - 1 - 1 // extra -1 for line because of drawing empty line
+	x := col - r.offsetX - BorderOffset - 1
+	if i < 0 || i >= playfield.Height() || x < 0 || x >= playfield.Width()*2 {
+		return 0, 0, false
+	}
+
+	return i, x / 2, true
+}
+
 func (r *PlayfieldRenderer) RedrawCell(i, j int, ck game.CellKind) {
 	r.term.SetCursor(r.offsetY+i+1+1, r.offsetX+BorderOffset+j*2+1)
 	r.renderCell(ck)
 }
+
+// previewWidth is the gap between the board and the preview plus four cells.
+const previewWidth = 2 + 4*2
+
+// LayoutSize returns the size of the board together with the preview of the next tetrominoes.
+func LayoutSize(playfield *game.Playfield, preview int) (lines, cols int) {
+	lines, cols = BoardSize(playfield)
+	if preview > 0 {
+		lines = max(lines, 2+3*preview)
+		cols += previewWidth
+	}
+	return lines, cols
+}
+
+// DrawPreview draws the next tetrominoes to the right of the board, each in a 4x2 slot.
+func (r *PlayfieldRenderer) DrawPreview(playfield *game.Playfield, next []*game.Tetromino) {
+	_, boardCols := BoardSize(playfield)
+	col := r.offsetX + boardCols + 2 + 1
+
+	r.term.SetCursor(r.offsetY+2, col)
+	r.term.Print("Next")
+
+	for k, tetro := range next {
+		top := r.offsetY + 3 + k*3
+		for y := range 2 {
+			r.term.SetCursor(top+y, col)
+			r.term.Print(strings.Repeat(" ", 8))
+		}
+
+		minX, minY := tetro.Points[0].X, tetro.Points[0].Y
+		for _, p := range tetro.Points {
+			minX, minY = min(minX, p.X), min(minY, p.Y)
+		}
+		for _, p := range tetro.Points {
+			r.term.SetCursor(top+p.Y-minY, col+(p.X-minX)*2)
+			r.renderCell(game.CellBlock)
+		}
+	}
+}
+
+// DrawMeter fills the column right of the board from the bottom, a cell per garbage line waiting to rise.
+func (r *PlayfieldRenderer) DrawMeter(playfield *game.Playfield, lines int) {
+	_, boardCols := BoardSize(playfield)
+	h := playfield.Height()
+	for i := range h {
+		r.term.SetCursor(r.offsetY+i+1+1, r.offsetX+boardCols+1) // extra +1 because of drawing empty line
+		if h-i <= lines {
+			r.colored(meterColor[r.color], "#")
+		} else {
+			r.term.Print(" ")
+		}
+	}
+}
+
+// DrawOverlay prints the lines over the middle of the playfield, the overlay grows past the walls of a small playfield.
+func (r *PlayfieldRenderer) DrawOverlay(playfield *game.Playfield, lines []string) {
+	width := playfield.Width() * 2
+	for _, line := range lines {
+		width = max(width, len(line))
+	}
+	left := max(1, r.offsetX+BorderOffset+1-(width-playfield.Width()*2)/2)
+	top := max(1, r.offsetY+1+1+(playfield.Height()-len(lines))/2) // extra +1 because of drawing empty line
+
+	for i, line := range lines {
+		r.term.SetCursor(top+i, left)
+		r.term.Printf("%-*s", width, line)
+	}
+}
+
+// OptionRow is a line of the options screen.
+type OptionRow struct {
+	Label string
+	Value string
+}
+
+// optionLabelWidth pads the labels of the options so that the values line up.
+const optionLabelWidth = 14
+
+// DrawOptions draws the options screen in place of the board, the selected row is marked with an arrow.
+func (r *PlayfieldRenderer) DrawOptions(rows []OptionRow, selected int) {
+	r.term.Clear()
+	r.term.SetCursor(r.offsetY+2, r.offsetX+1)
+	r.term.Print("Options")
+
+	for i, row := range rows {
+		marker := ' '
+		if i == selected {
+			marker = '>'
+		}
+		r.term.SetCursor(r.offsetY+4+i, r.offsetX+1)
+		r.term.Printf("%c %-*s < %s >", marker, optionLabelWidth, row.Label, row.Value)
+	}
+
+	r.term.SetCursor(r.offsetY+5+len(rows), r.offsetX+1)
+	r.term.Print("up/down select, left/right change")
+	r.term.SetCursor(r.offsetY+6+len(rows), r.offsetX+1)
+	r.term.Print("enter, esc or o to close")
+}
+
+// RowAt maps the terminal line (1-based, as in mouse events) to the row of the menu or the options
+// drawn with n rows.
+func (r *PlayfieldRenderer) RowAt(line, n int) (int, bool) {
+	i := line - r.offsetY - 4
+	if i < 0 || i >= n {
+		return 0, false
+	}
+	return i, true
+}
+
+// ChangeAt tells which arrow of the options row is at the terminal column: -1 for "<", 1 for ">", 0 for none.
+func (r *PlayfieldRenderer) ChangeAt(row OptionRow, col int) int {
+	less := r.offsetX + 1 + 2 + max(optionLabelWidth, utf8.RuneCountInString(row.Label)) + 1
+	switch col {
+	case less:
+		return -1
+	case less + 2 + utf8.RuneCountInString(row.Value) + 1:
+		return 1
+	}
+	return 0
+}
+
+// DrawMenu draws a menu in place of the board, the selected item is marked with an arrow.
+func (r *PlayfieldRenderer) DrawMenu(title string, items []string, selected int, footer ...string) {
+	lines := make([]string, len(items))
+	for i, item := range items {
+		marker := ' '
+		if i == selected {
+			marker = '>'
+		}
+		lines[i] = fmt.Sprintf("%c %s", marker, item)
+	}
+	r.DrawPage(title, lines, footer...)
+}
+
+// DrawPage draws a titled page of text in place of the board.
+func (r *PlayfieldRenderer) DrawPage(title string, lines []string, footer ...string) {
+	r.term.Clear()
+	r.term.SetCursor(r.offsetY+2, r.offsetX+1)
+	r.term.Print(title)
+
+	for i, line := range lines {
+		r.term.SetCursor(r.offsetY+4+i, r.offsetX+1)
+		r.term.Print(line)
+	}
+	for i, line := range footer {
+		r.term.SetCursor(r.offsetY+5+len(lines)+i, r.offsetX+1)
+		r.term.Print(line)
+	}
+}
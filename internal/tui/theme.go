@@ -0,0 +1,82 @@
+package tui
+
+import (
+	"maps"
+	"slices"
+	"strings"
+)
+
+// Theme sets how cells are drawn, every glyph takes two columns.
+type Theme struct {
+	Block  string
+	Empty  string
+	Hidden string
+	Ghost  string // landing position of the falling tetromino
+}
+
+const DefaultTheme = "classic"
+
+var Themes = map[string]Theme{
+	"classic": {Block: "[]", Empty: " .", Hidden: "  ", Ghost: "::"},
+	"ascii":   {Block: "##", Empty: " .", Hidden: "  ", Ghost: "++"},
+	"blocks":  {Block: "██", Empty: " ·", Hidden: "  ", Ghost: "░░"},
+}
+
+func ThemeNames() []string {
+	return slices.Sorted(maps.Keys(Themes))
+}
+
+// ColorMode is how many colours the terminal can show, blocks are drawn monochrome without colours.
+type ColorMode string
+
+const (
+	ColorAuto      ColorMode = "auto" // detect by the environment
+	ColorNone      ColorMode = "none"
+	Color16        ColorMode = "16"
+	Color256       ColorMode = "256"
+	ColorTrueColor ColorMode = "truecolor"
+)
+
+var ColorModes = []ColorMode{ColorAuto, ColorNone, Color16, Color256, ColorTrueColor}
+
+// DetectColorMode guesses the colour support by the environment, honouring NO_COLOR (https://no-color.org).
+func DetectColorMode(getenv func(key string) string) ColorMode {
+	if getenv("NO_COLOR") != "" {
+		return ColorNone
+	}
+
+	switch getenv("COLORTERM") {
+	case "truecolor", "24bit":
+		return ColorTrueColor
+	}
+
+	term := getenv("TERM")
+	switch {
+	case term == "" || term == "dumb":
+		return ColorNone
+	case strings.Contains(term, "256color"):
+		return Color256
+	}
+	return Color16
+}
+
+// blockColor, ghostColor and meterColor are SGR sequences for each mode, the classic green on black with the garbage in red.
+var (
+	blockColor = map[ColorMode]string{
+		Color16:        "\033[32m",
+		Color256:       "\033[38;5;34m",
+		ColorTrueColor: "\033[38;2;40;200;90m",
+	}
+	ghostColor = map[ColorMode]string{
+		Color16:        "\033[90m",
+		Color256:       "\033[38;5;240m",
+		ColorTrueColor: "\033[38;2;90;90;90m",
+	}
+	meterColor = map[ColorMode]string{
+		Color16:        "\033[31m",
+		Color256:       "\033[38;5;160m",
+		ColorTrueColor: "\033[38;2;220;50;50m",
+	}
+)
+
+const resetColor = "\033[39m"
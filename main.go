@@ -3,44 +3,97 @@ package main
 import (
 	"context"
 	"fmt"
-	"math/rand/v2"
+	"io"
+	"log/slog"
+	"math"
 	"os"
-	"os/exec"
 	"os/signal"
+	"runtime/debug"
 	"slices"
+	"strings"
 	"syscall"
 	"time"
+	"unicode"
 
+	"github.com/opennikish/tetris/internal/autorepeat"
+	"github.com/opennikish/tetris/internal/bot"
 	"github.com/opennikish/tetris/internal/game"
+	"github.com/opennikish/tetris/internal/keymap"
+	"github.com/opennikish/tetris/internal/netplay"
+	"github.com/opennikish/tetris/internal/replay"
+	"github.com/opennikish/tetris/internal/scores"
+	"github.com/opennikish/tetris/internal/settings"
+	"github.com/opennikish/tetris/internal/spectate"
 	"github.com/opennikish/tetris/internal/terminal"
 	"github.com/opennikish/tetris/internal/tui"
 )
 
 func main() {
-	ctx := context.Background()
-
-	term := terminal.NewTerminal(os.Stdin, os.Stdout, exec_)
-	app := NewApp(
-		game.NewGameplay(func(n int) int { return rand.IntN(n) }),
-		term,
-		tui.NewPlayfieldRenderer(term, 0, 0),
-		NewRealTicker(500*time.Millisecond),		
-	)
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
 
-	if err := app.Start(ctx); err != nil {
-		fmt.Fprintf(os.Stderr, "%s\n", err)
-		os.Exit(1)
-	}
+const (
+	defaultGravity = 500 * time.Millisecond
+	frameInterval  = 16 * time.Millisecond // ~60 FPS for auto-repeat
+)
+
+// gravityFor speeds the base gravity up by 20% every level, but not faster than a frame.
+func gravityFor(base time.Duration, level int) time.Duration {
+	d := time.Duration(float64(base) * math.Pow(0.8, float64(level-1)))
+	return max(d, frameInterval)
 }
 
 type App struct {
-	gameplay   *game.Gameplay
-	term       *terminal.Terminal
-	renderer   *tui.PlayfieldRenderer
-	ticker     Ticker
-	tickCount  int
-	ctxCancel  context.CancelFunc
-	fieldCache [][]game.CellKind
+	boards      []*board // the first one plays alone, the second joins in versus
+	versus      bool
+	loser       *board // the board that topped out first in versus
+	term        *terminal.Terminal
+	keymap      *keymap.Keymap // keys of the settings, for the menus and a single player
+	frames      Ticker
+	baseGravity time.Duration
+	tickCount   int
+	ctxCancel   context.CancelFunc
+	tooSmall    bool
+	paused      bool
+	options     bool // the options screen is open
+	optionsRow  int
+	menu        menuScreen
+	menuRow     int
+	newGame     func(mode string) []*game.Gameplay // starts the games picked in the menu, nil without the menu
+	sandbox     bool
+	mouse       bool // the terminal reports the mouse, see syncMouse
+	settings    settings.Settings
+	save        func(settings.Settings) error
+	showGhost   bool
+	preview     int
+	scores      *scores.Store
+	mode        string
+	order       scores.Order
+	player      string
+	nameEntry   bool // the game is over and the player types the name for the high-score table
+	gameOver    bool // the summary of the finished game is shown over the board
+	overReason  game.GameOverReason
+	name        []rune
+	rank        int
+	now         func() time.Time
+	getenv      func(key string) string // the environment of the terminal, for its colors
+	elapsed     time.Duration           // played time without pauses
+	lastFrame   time.Time
+	bot         *bot.Bot
+	botPlan     []game.Command
+	botPlanned  bool // the plan is made for the falling tetromino
+	botMoved    time.Time
+	recorder    *replay.Recorder
+	replay      <-chan replay.Step
+	replaying   bool
+	remote      *netplay.Conn // the opponent of a network game
+	remoteMsgs  <-chan netplay.Message
+	netOver     netOver
+	broadcast   *spectate.Broadcaster
+	stopProcess func() error
+	logger      *slog.Logger
+	offsetX     int
+	offsetY     int
 }
 
 func NewApp(
@@ -48,157 +101,1070 @@ func NewApp(
 	term *terminal.Terminal,
 	renderer *tui.PlayfieldRenderer,
 	ticker Ticker,
+	keymap *keymap.Keymap,
+	frames Ticker,
+	repeater *autorepeat.Repeater,
 ) *App {
+	gravity := gravityFor(defaultGravity, gameplay.Level())
+	b := &board{
+		gameplay:    gameplay,
+		renderer:    renderer,
+		keymap:      keymap,
+		ticker:      ticker,
+		repeater:    repeater,
+		gravity:     gravity,
+		currGravity: gravity,
+	}
 	return &App{
-		gameplay: gameplay,
-		renderer: renderer,
-		term:     term,
-		ticker:   ticker,
+		boards:      []*board{b},
+		term:        term,
+		keymap:      keymap,
+		frames:      frames,
+		baseGravity: defaultGravity,
+		settings:    settings.Default(),
+		stopProcess: terminal.StopProcess,
+		logger:      slog.New(slog.NewTextHandler(io.Discard, nil)),
+		now:         time.Now,
+		getenv:      os.Getenv,
 	}
 }
 
-func (a *App) Start(ctx context.Context) error {
-	log("starting..")
+// SetLogger sends the log of the app, its game and terminal to the logger, it's discarded by default.
+func (a *App) SetLogger(l *slog.Logger) {
+	a.logger = l
+	for _, b := range a.boards {
+		if b.gameplay != nil {
+			b.gameplay.SetLogger(l)
+		}
+	}
+	a.term.SetLogger(l)
+}
+
+func (a *App) Start(ctx context.Context) (err error) {
+	a.logger.Info("starting")
 	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
 	a.ctxCancel = stop
 	defer stop()
 
-	if err := a.term.UseRawModeNoEcho(); err != nil {
+	defer func() {
+		if r := recover(); r != nil {
+			stack := debug.Stack()
+			a.logger.Error("panic", "err", r, "stack", string(stack))
+			// the terminal is restored by now, the report is printed even without a log
+			err = fmt.Errorf("panic: %v\n\n%s\n%s", r, stack, a.dumpState())
+		}
+	}()
+
+	if err := a.setupTerminal(); err != nil {
 		return fmt.Errorf("configure terminal: %w", err)
 	}
+	defer a.restoreTerminal()
+
+	inputCtx, stopInput := context.WithCancel(ctx)
+	events, errc := a.term.WatchInput(inputCtx)
+	defer func() {
+		stopInput()
+		for range errc {
+		}
+		a.logger.Debug("input reader stopped")
+	}()
+	a.logger.Debug("input reader started")
+
+	resized := a.term.WatchResize(ctx)
+	suspended, continued := a.term.WatchSuspend(ctx)
 
-	keys, errc := a.term.WatchKeystrokes(ctx)
-	log("keystroke reader kicked off")
+	for _, b := range a.boards {
+		b.ticker.Start()
+		defer b.ticker.Stop()
+	}
 
-	a.ticker.Start()
-	defer a.ticker.Stop()
+	a.frames.Start()
+	defer a.frames.Stop()
 
-	a.renderer.Draw(a.gameplay.Field())
+	a.term.BeginFrame()
+	a.layout()
+	if !a.tooSmall && a.menu != menuNone {
+		a.drawMenu()
+	} else if !a.tooSmall {
+		a.drawBoard()
+	}
+	a.term.EndFrame()
 
-	a.fieldCache = a.createFieldCache(a.gameplay.Field().Height(), a.gameplay.Field().Width())
+	for _, b := range a.playing() {
+		b.cacheField()
+	}
 
-	log("start loop")
+	ticks := a.boards[0].ticker.Channel()
+	if a.replaying {
+		ticks = nil // the gravity comes from the recording
+	}
+	if a.remote != nil {
+		a.remoteMsgs = a.remote.Receive(ctx)
+	}
+
+	a.logger.Debug("start loop")
 	for {
+		steps := a.replay
+		if a.halted() {
+			steps = nil
+		}
+		var rivalTicks <-chan time.Time
+		if a.versus && a.remote == nil {
+			rivalTicks = a.boards[1].ticker.Channel() // the opponent of a network game ticks on its side
+		}
+
 		select {
-		case k := <-keys:
-			a.onInput(k)
-		case <-a.ticker.Channel():
-			a.onTick()
+		case e := <-events:
+			switch evt := e.(type) {
+			case terminal.Key:
+				a.onInput(evt)
+			case terminal.Mouse:
+				a.onMouse(evt)
+			}
+		case <-ticks:
+			a.onTick(a.boards[0])
+		case <-rivalTicks:
+			a.onTick(a.boards[1])
+		case s, ok := <-steps:
+			a.onReplayStep(s, ok)
+		case m, ok := <-a.remoteMsgs:
+			a.onRemote(m, ok)
+		case <-a.frames.Channel():
+			a.onFrame()
+		case <-resized:
+			a.onResize()
+		case <-suspended:
+			a.onSuspend()
+		case <-continued:
+			a.onContinue()
 		case <-ctx.Done():
-			a.term.SetCursor(a.gameplay.Field().Height()+4, 0)
-			a.term.Print("Bye")
-			log("stop loop")
+			a.logger.Info("stop loop")
 			return nil
-		case err := <-errc:
+		case err, ok := <-errc:
+			if !ok {
+				a.logger.Info("stop loop")
+				return nil // closed when the ctx is done, e.g. by quit
+			}
 			return fmt.Errorf("read ui commands: %w", err)
 		}
 	}
 }
 
-func (a *App) createFieldCache(h, w int) [][]game.CellKind {
-	cache := make([][]game.CellKind, h)
-	for i := range h {
-		cache[i] = make([]game.CellKind, w)
-		for j := range w {
-			cache[i][j] = a.gameplay.Field().Cell(i, j)
+func (a *App) setupTerminal() error {
+	if err := a.term.UseRawModeNoEcho(); err != nil {
+		return err
+	}
+
+	a.term.EnterAltScreen()
+	a.term.HideCursor()
+	a.term.EnableKeyboardProtocol()
+	a.syncMouse()
+
+	return nil
+}
+
+// restoreTerminal undoes setupTerminal, it's called on every exit path including panics.
+func (a *App) restoreTerminal() {
+	a.resetTerminal()
+	a.term.Println("Bye")
+}
+
+func (a *App) resetTerminal() {
+	a.term.FlushFrames()
+	a.term.DisableKeyboardProtocol()
+	if a.mouse {
+		a.term.DisableMouse()
+		a.mouse = false
+	}
+	a.term.ShowCursor()
+	a.term.ExitAltScreen()
+
+	if err := a.term.RestoreMode(); err != nil {
+		a.logger.Error("restore terminal", "err", err)
+	}
+}
+
+// dumpState logs the game state to help reproducing a crash and returns it as text.
+func (a *App) dumpState() string {
+	var sb strings.Builder
+	a.logger.Error("state", "tick", a.tickCount, "too_small", a.tooSmall, "paused", a.paused, "menu", a.menu, "versus", a.versus, "offset_x", a.offsetX, "offset_y", a.offsetY)
+	fmt.Fprintf(&sb, "tick=%d too_small=%t paused=%t menu=%v versus=%t offset=%d,%d\n", a.tickCount, a.tooSmall, a.paused, a.menu, a.versus, a.offsetX, a.offsetY)
+	for _, b := range a.playing() {
+		if b.gameplay == nil {
+			continue
+		}
+		tetro, field := fmt.Sprint(b.gameplay.CurrentTetromino().Points), b.gameplay.Field().String()
+		a.logger.Error("state", "tetromino", tetro, "playfield", field)
+		fmt.Fprintf(&sb, "tetromino=%s\n%s", tetro, field)
+	}
+	return sb.String()
+}
+
+// layout centres the boards in the terminal window, or pauses the game if the board doesn't fit.
+// Keeps the current layout when the window size is unknown, e.g. output isn't a terminal.
+func (a *App) layout() {
+	lines, cols, err := a.term.Size()
+	if err != nil {
+		a.logger.Warn("window size", "err", err)
+		return
+	}
+
+	needLines, needCols := a.layoutSize()
+	if lines < needLines || cols < needCols {
+		a.tooSmall = true
+		a.term.Clear()
+		a.term.SetCursor(1, 1)
+		a.term.Printf("Terminal too small: need %dx%d, got %dx%d", needCols, needLines, cols, lines)
+		a.term.SetCursor(2, 1)
+		hint := "Game paused, enlarge the window"
+		if keys := a.keymap.Keys(keymap.Quit); len(keys) > 0 {
+			hint += fmt.Sprintf(" or press %s to quit", keys[0])
 		}
+		a.term.Print(hint)
+		return
 	}
 
-	return cache
+	a.tooSmall = false
+	a.offsetX, a.offsetY = (cols-needCols)/2, (lines-needLines)/2
+	x := a.offsetX
+	for _, b := range a.playing() {
+		b.renderer.SetOffset(x, a.offsetY)
+		x += a.boardCols(b) + versusGap
+	}
 }
 
-func (a *App) onTick() {
-	log("tick: %d", a.tickCount)
+func (a *App) onResize() {
+	a.term.BeginFrame()
+	defer a.term.EndFrame()
+
+	a.layout()
+	if !a.tooSmall {
+		a.redraw()
+	}
+}
+
+// redraw draws the whole screen from scratch.
+func (a *App) redraw() {
+	if a.options {
+		a.drawOptions()
+		return
+	}
+	if a.menu != menuNone {
+		a.drawMenu()
+		return
+	}
+
+	a.drawBoard()
+	for _, b := range a.playing() {
+		if a.gameOver {
+			b.renderer.DrawOverlay(b.gameplay.Field(), a.summaryLines(b))
+		} else if !b.gameplay.Field().IsHidden(b.gameplay.CurrentTetromino()) {
+			a.drawPiece(b)
+		}
+	}
+	if a.gameOver {
+		return
+	}
+	if a.paused {
+		a.drawStatus("Paused, press any key")
+	}
+	if a.nameEntry {
+		a.drawNameEntry()
+	}
+}
+
+// drawBoard draws the playfields without the falling tetrominoes.
+func (a *App) drawBoard() {
+	a.term.Clear()
+	for _, b := range a.playing() {
+		b.ghost = nil
+		b.renderer.Draw(b.gameplay.Field())
+		a.drawPreview(b)
+	}
+	a.drawMeters()
+}
+
+func (a *App) drawPreview(b *board) {
+	if a.preview > 0 {
+		b.renderer.DrawPreview(b.gameplay.Field(), b.gameplay.Preview(a.preview))
+	}
+}
+
+// drawPiece draws the falling tetromino over its ghost.
+func (a *App) drawPiece(b *board) {
+	if a.showGhost {
+		b.ghost = b.gameplay.Ghost()
+		b.renderer.DrawGhost(b.ghost)
+	}
+	b.renderer.DrawTetro(b.gameplay.CurrentTetromino(), game.CellBlock)
+}
+
+// erasePiece erases the tetromino drawn at the given position and its ghost.
+func (a *App) erasePiece(b *board, tetro *game.Tetromino) {
+	if b.ghost != nil {
+		b.renderer.DrawTetro(b.ghost, game.CellEmpty)
+		b.ghost = nil
+	}
+	b.renderer.DrawTetro(tetro, game.CellEmpty)
+}
+
+// drawStatus prints the messages line by line right under the board, empty message clears the line.
+func (a *App) drawStatus(msgs ...string) {
+	lines, cols := a.layoutSize()
+	for i, msg := range msgs {
+		a.term.SetCursor(a.offsetY+lines+1+i, a.offsetX+1)
+		a.term.Printf("%-*s", cols, msg)
+	}
+}
+
+// halted tells whether the game clock and the controls are frozen.
+func (a *App) halted() bool {
+	return a.tooSmall || a.paused || a.options || a.nameEntry || a.gameOver || a.menu != menuNone
+}
+
+// onSuspend gives the terminal back to the shell and stops the process on Ctrl-Z.
+func (a *App) onSuspend() {
+	if a.remote != nil {
+		a.logger.Info("suspend ignored in a network game")
+		a.term.BeginFrame()
+		a.drawStatus("A network game can't be suspended")
+		a.term.EndFrame()
+		return
+	}
+	a.logger.Info("suspend")
+	a.paused = true
+	a.resetTerminal()
+
+	if err := a.stopProcess(); err != nil {
+		a.logger.Error("stop process", "err", err)
+		a.onContinue()
+	}
+}
+
+// onContinue takes the terminal back after the process was stopped and waits for a key to resume the game.
+func (a *App) onContinue() {
+	a.logger.Info("continue")
+	if err := a.setupTerminal(); err != nil {
+		a.logger.Error("configure terminal", "err", err)
+	}
+	a.paused = a.menu == menuNone // the menu waits for a key anyway
+
+	a.term.BeginFrame()
+	defer a.term.EndFrame()
+
+	a.term.Clear()
+	a.layout()
+	if !a.tooSmall {
+		a.redraw()
+	}
+}
+
+func (a *App) resume() {
+	a.paused = false
+	if a.tooSmall {
+		return
+	}
+
+	a.term.BeginFrame()
+	defer a.term.EndFrame()
+	a.drawStatus("")
+}
+
+func (a *App) onTick(b *board) {
+	if a.halted() || b.over {
+		return
+	}
+
+	a.logger.Debug("tick", "n", a.tickCount)
 	a.tickCount++
+	a.record((*replay.Recorder).Gravity)
+	a.send(b, (*netplay.Conn).Gravity)
 
-	if !a.gameplay.Field().IsHidden(a.gameplay.CurrentTetromino()) {
-		a.renderer.DrawTetro(a.gameplay.CurrentTetromino(), game.CellEmpty)
+	a.term.BeginFrame()
+	defer a.term.EndFrame()
+
+	if !b.gameplay.Field().IsHidden(b.gameplay.CurrentTetromino()) {
+		a.erasePiece(b, b.gameplay.CurrentTetromino())
 	}
 
-	events := a.gameplay.Update()
+	events := b.gameplay.Update()
 
 	for _, e := range events {
 		switch evt := e.(type) {
+		case game.TetroLockedEvent:
+			if b == a.boards[0] {
+				a.botPlanned = false
+			}
+			b.repeater.Recharge()
+			a.drawPreview(b)
 		case game.LinesUpdatedEvent:
-			log("line updated event")
-
-			a.clearLines(evt.Cleared)
-			log("lines cleared: %v", evt.Cleared)
+			a.clearLines(b, evt.Cleared)
+			a.redrawLines(b)
 
-			a.redrawLines()
-			log("lines redrawed")
+			b.gravity = gravityFor(a.baseGravity, b.gameplay.Level())
+			a.syncGravity(b)
+		case game.FinesseFaultEvent:
+			if !a.versus {
+				a.drawStatus(fmt.Sprintf("Finesse faults: %d", b.gameplay.Faults()))
+			}
+		case game.AttackEvent:
+			a.onAttack(b, evt.Lines)
 		case game.GameOverEvent:
-			a.quit()
+			if a.remote != nil {
+				a.onTopOut(b, evt.Reason)
+				continue
+			}
+			if a.versus {
+				a.loser = b
+			}
+			a.onGameOver(evt.Reason)
 		}
 	}
+	if a.nameEntry || a.gameOver || b.over {
+		return
+	}
 
-	a.renderer.DrawTetro(a.gameplay.CurrentTetromino(), game.CellBlock)
+	a.drawMeters()
+	a.drawPiece(b)
 }
 
-func (a *App) clearLines(lines []int) {
-	w := a.gameplay.Field().Width()
+func (a *App) clearLines(b *board, lines []int) {
+	w := b.gameplay.Field().Width()
 	for _, i := range slices.Backward(lines) {
 		empty := make([]game.CellKind, w)
 		fill(empty, game.CellEmpty)
-		a.renderer.RedrawPlayfieldLine(i, empty)
-		a.fieldCache[i] = empty
+		b.renderer.RedrawPlayfieldLine(i, empty)
+		b.fieldCache[i] = empty
 	}
 }
 
-func (a *App) redrawLines() {
-	for i := range a.gameplay.Field().Height() {
-		for j := range a.gameplay.Field().Width() {
-			actual := a.gameplay.Field().Cell(i, j)
-			if a.fieldCache[i][j] != actual {
-				a.renderer.RedrawCell(i, j, actual)
-				a.fieldCache[i][j] = actual
+func (a *App) redrawLines(b *board) {
+	field := b.gameplay.Field()
+	for i := range field.Height() {
+		for j := range field.Width() {
+			actual := field.Cell(i, j)
+			if b.fieldCache[i][j] != actual {
+				b.renderer.RedrawCell(i, j, actual)
+				b.fieldCache[i][j] = actual
 			}
 		}
 	}
 }
 
+// Pressed Ctrl-C and Ctrl-Z, terminals with the kitty keyboard protocol send them instead of raising SIGINT
+// and SIGTSTP. A remote terminal sends Ctrl-Z as is, stopping the server for it would stop every session.
+var (
+	ctrlC = terminal.Key{Kind: terminal.Letter, Char: 'c', Mod: terminal.ModCtrl}
+	ctrlZ = terminal.Key{Kind: terminal.Letter, Char: 'z', Mod: terminal.ModCtrl}
+)
+
 func (a *App) onInput(k terminal.Key) {
-	if k.Char == 'q' {
+	if k == ctrlC {
+		a.logger.Info("interrupt")
+		a.quit()
+		return
+	}
+	if k == ctrlZ {
+		if a.term.Remote() {
+			a.logger.Debug("suspend ignored on a remote terminal")
+			return
+		}
+		a.onSuspend()
+		return
+	}
+	action, ok := a.keymap.Action(k)
+	if a.paused && k.Action == terminal.Press && action != keymap.Quit {
+		a.resume()
+		return
+	}
+	if a.options {
+		if k.Action != terminal.Release {
+			a.onOptionsKey(k, action)
+		}
+		return
+	}
+	if a.nameEntry {
+		if k.Action != terminal.Release {
+			a.onNameKey(k)
+		}
+		return
+	}
+	if a.gameOver {
+		if k.Action == terminal.Press {
+			a.onSummaryKey(k, action)
+		}
+		return
+	}
+	if a.menu != menuNone {
+		if k.Action == terminal.Press {
+			a.onMenuKey(k, action)
+		}
+		return
+	}
+	b := a.boards[0]
+	if a.versus && a.remote == nil {
+		b, action, ok = a.boardAction(k)
+	}
+	if !ok {
+		a.logger.Debug("unsupported key", "key", k.String())
+		return
+	}
+	if a.term.ReportsKeyReleases() {
+		b.repeater.UseReleaseEvents()
+	}
+
+	cmd, isCmd := action.Command()
+	switch k.Action {
+	case terminal.Release:
+		if isCmd {
+			b.repeater.Release(cmd)
+			a.syncGravity(b)
+		}
+		return
+	case terminal.Repeat:
+		return // the repeater makes its own repeats
+	}
+
+	if action == keymap.Quit {
+		a.quitGame()
+		return
+	}
+	if a.halted() || a.replaying {
+		return
+	}
+	if action == keymap.Options {
+		if a.remote != nil {
+			a.term.BeginFrame()
+			a.drawStatus("The options are off in a network game")
+			a.term.EndFrame()
+			return
+		}
+		a.openOptions()
+		return
+	}
+	if isCmd && !b.over && (a.bot == nil || b != a.boards[0]) {
+		if b.repeater.Press(cmd) {
+			a.press(b, cmd)
+			a.perform(b, cmd)
+		}
+		a.syncGravity(b)
+	}
+}
+
+// syncMouse turns the mouse reporting on for the screens taking clicks: the menus, the options and the sandbox.
+func (a *App) syncMouse() {
+	enabled := a.menu != menuNone || a.options || a.sandbox
+	if a.mouse == enabled {
+		return
+	}
+	a.mouse = enabled
+	if enabled {
+		a.term.EnableMouse()
+	} else {
+		a.term.DisableMouse()
+	}
+}
+
+// onMouse picks the clicked rows of the menus and the options, and toggles the clicked cells in sandbox.
+func (a *App) onMouse(m terminal.Mouse) {
+	if a.tooSmall || m.Button != terminal.MouseLeft {
+		return
+	}
+	switch {
+	case a.options:
+		if m.Action == terminal.MousePress {
+			a.onOptionsClick(m)
+		}
+	case a.menu != menuNone:
+		if m.Action == terminal.MousePress {
+			a.onMenuClick(m)
+		}
+	case a.sandbox && !a.halted():
+		a.onSandboxMouse(m)
+	}
+}
+
+// onSandboxMouse toggles the clicked cells, dragging paints over several cells.
+// The cells under the falling tetromino stay as they are.
+func (a *App) onSandboxMouse(m terminal.Mouse) {
+	if m.Action != terminal.MousePress && m.Action != terminal.MouseDrag {
+		return
+	}
+
+	b := a.boards[0]
+	field := b.gameplay.Field()
+	i, j, ok := b.renderer.CellAt(field, m.Line, m.Col)
+	if !ok || field.Covers(b.gameplay.CurrentTetromino(), i, j) {
+		return
+	}
+	if m.Action == terminal.MouseDrag && field.Cell(i, j) == game.CellBlock {
+		return
+	}
+
+	a.term.BeginFrame()
+	defer a.term.EndFrame()
+
+	ck := field.ToggleCell(i, j)
+	b.fieldCache[i][j] = ck
+	b.renderer.RedrawCell(i, j, ck)
+	if !field.IsHidden(b.gameplay.CurrentTetromino()) {
+		a.erasePiece(b, b.gameplay.CurrentTetromino()) // the ghost might land elsewhere now
+		a.drawPiece(b)
+	}
+}
+
+// SetSettings applies the user settings, the options screen changes them and saves with the save func.
+func (a *App) SetSettings(s settings.Settings, save func(settings.Settings) error) {
+	a.settings = s
+	a.save = save
+	a.applySettings()
+}
+
+// SetEnv replaces the process environment the colors of the terminal are detected from,
+// a remote session has the one of its client.
+func (a *App) SetEnv(getenv func(key string) string) {
+	a.getenv = getenv
+	a.applySettings()
+}
+
+func (a *App) applySettings() {
+	s := a.settings
+	a.baseGravity = time.Duration(s.Gravity)
+
+	km, err := keymap.New(s.Keys)
+	if err != nil {
+		a.logger.Warn("apply key bindings", "err", err)
+	} else {
+		a.keymap = km
+	}
+	if !a.versus || a.remote != nil {
+		a.boards[0].keymap = a.keymap
+	}
+
+	mode := s.ColorMode
+	if mode == tui.ColorAuto {
+		mode = tui.DetectColorMode(a.getenv)
+	}
+	for _, b := range a.boards {
+		if b.gameplay != nil {
+			b.gravity = gravityFor(a.baseGravity, b.gameplay.Level())
+		}
+		b.repeater.SetConfig(s.Repeat())
+		b.renderer.SetTheme(tui.Themes[s.Theme])
+		b.renderer.SetColorMode(mode)
+	}
+
+	a.preview = s.Preview
+	a.showGhost = s.Ghost
+}
+
+func (a *App) openOptions() {
+	a.options = true
+	a.optionsRow = 0
+	a.syncMouse()
+	a.drawOptions()
+}
+
+func (a *App) drawOptions() {
+	a.term.BeginFrame()
+	defer a.term.EndFrame()
+
+	rows := make([]tui.OptionRow, len(settings.Options))
+	for i, opt := range settings.Options {
+		rows[i] = tui.OptionRow{Label: opt.Label, Value: opt.Value(a.settings)}
+	}
+	a.boards[0].renderer.DrawOptions(rows, a.optionsRow)
+}
+
+// onOptionsKey navigates the options screen with arrows or the movement keys of the keymap.
+func (a *App) onOptionsKey(k terminal.Key, action keymap.Action) {
+	n := len(settings.Options)
+	switch {
+	case action == keymap.Quit:
+		a.closeOptions()
+		if a.menu == menuNone {
+			a.quitGame()
+		} else {
+			a.quit()
+		}
+		return
+	case k.Kind == terminal.Enter || k.Kind == terminal.Esc || action == keymap.Options:
+		a.closeOptions()
+		return
+	case k.Kind == terminal.Up || action == keymap.Rotate:
+		a.optionsRow = (a.optionsRow - 1 + n) % n
+	case k.Kind == terminal.Down || action == keymap.SoftDrop:
+		a.optionsRow = (a.optionsRow + 1) % n
+	case k.Kind == terminal.Left || action == keymap.MoveLeft:
+		settings.Options[a.optionsRow].Change(&a.settings, -1)
+		a.applySettings()
+	case k.Kind == terminal.Right || action == keymap.MoveRight:
+		settings.Options[a.optionsRow].Change(&a.settings, 1)
+		a.applySettings()
+	default:
+		return
+	}
+	a.drawOptions()
+}
+
+// onOptionsClick selects the clicked row, a click on its arrows changes the value.
+func (a *App) onOptionsClick(m terminal.Mouse) {
+	i, ok := a.boards[0].renderer.RowAt(m.Line, len(settings.Options))
+	if !ok {
+		return
+	}
+	a.optionsRow = i
+	opt := settings.Options[i]
+	if d := a.boards[0].renderer.ChangeAt(tui.OptionRow{Label: opt.Label, Value: opt.Value(a.settings)}, m.Col); d != 0 {
+		opt.Change(&a.settings, d)
+		a.applySettings()
+	}
+	a.drawOptions()
+}
+
+// closeOptions saves the settings and gets back to the game or the menu.
+func (a *App) closeOptions() {
+	a.options = false
+	a.syncMouse()
+	if a.save != nil {
+		if err := a.save(a.settings); err != nil {
+			a.logger.Error("save settings", "err", err)
+		}
+	}
+	for _, b := range a.playing() {
+		a.syncGravity(b)
+	}
+
+	a.term.BeginFrame()
+	defer a.term.EndFrame()
+
+	a.term.Clear()
+	a.layout()
+	if !a.tooSmall {
+		a.redraw()
+	}
+}
+
+// SetScores enables the high-score table of the mode, the player name is offered when a game makes the table.
+func (a *App) SetScores(store *scores.Store, mode string, order scores.Order, player string) {
+	a.scores = store
+	a.mode = mode
+	a.order = order
+	a.player = player
+}
+
+// reasonQuit ends the game by the quit key.
+const reasonQuit game.GameOverReason = "quit"
+
+// quitGame ends the game with the summary, or quits right away when no new game can be started.
+func (a *App) quitGame() {
+	if a.newGame == nil {
 		a.quit()
 		return
 	}
-	if cmd, ok := a.cmdByKey(k); ok {
-		a.renderer.DrawTetro(a.gameplay.CurrentTetromino(), game.CellEmpty)
-		log("cmd: %s", cmd)
-		a.gameplay.HandleCommand(cmd)
-		a.renderer.DrawTetro(a.gameplay.CurrentTetromino(), game.CellBlock)
+	a.onGameOver(reasonQuit)
+}
+
+// onGameOver asks for the name if the game makes the high-score table, then shows the summary.
+func (a *App) onGameOver(reason game.GameOverReason) {
+	a.logger.Debug("game over", "reason", reason)
+	a.overReason = reason
+	if a.scores == nil || a.sandbox || a.versus || a.bot != nil || a.order == scores.ByTime && !a.boards[0].gameplay.GoalReached() {
+		a.showSummary()
+		return
+	}
+
+	rank, ok, err := a.scores.Rank(a.mode, a.order, a.scoreEntry())
+	if err != nil {
+		a.logger.Error("rank score", "err", err)
+	}
+	if err != nil || !ok {
+		a.showSummary()
+		return
+	}
+
+	a.nameEntry = true
+	a.rank = rank
+	a.name = []rune(a.player)
+	a.drawNameEntry()
+}
+
+func (a *App) scoreEntry() scores.Entry {
+	g := a.boards[0].gameplay
+	return scores.Entry{
+		Name:     string(a.name),
+		Score:    g.Score(),
+		Lines:    g.Lines(),
+		Level:    g.Level(),
+		Duration: a.elapsed.Round(time.Millisecond),
+		Date:     a.now(),
 	}
 }
 
-func (a *App) cmdByKey(key terminal.Key) (game.Command, bool) {
-	switch key.Kind {
-	case terminal.Right:
-		return game.MoveRight, true
-	case terminal.Left:
-		return game.MoveLeft, true
-	case terminal.Up:
-		return game.Rotate, true
-	case terminal.Letter:
-		switch key.Char {
-		case ' ':
-			return game.HardDrop, true
+func (a *App) drawNameEntry() {
+	a.term.BeginFrame()
+	defer a.term.EndFrame()
+
+	a.drawStatus(
+		fmt.Sprintf("High score #%d: %d", a.rank, a.boards[0].gameplay.Score()),
+		fmt.Sprintf("Name: %s_", string(a.name)),
+		"enter saves, esc skips",
+	)
+}
+
+// maxNameLen keeps the names fit the listing.
+const maxNameLen = 16
+
+func (a *App) onNameKey(k terminal.Key) {
+	switch {
+	case k.Kind == terminal.Enter:
+		a.name = []rune(strings.TrimSpace(string(a.name)))
+		if len(a.name) == 0 {
+			a.name = []rune("anonymous")
+		}
+		rank, err := a.scores.Add(a.mode, a.order, a.scoreEntry())
+		if err != nil {
+			a.logger.Error("save score", "err", err)
+		} else {
+			a.logger.Info("score saved", "mode", a.mode, "rank", rank)
+		}
+		a.showSummary()
+		return
+	case k.Kind == terminal.Esc:
+		a.showSummary()
+		return
+	case k.Kind == terminal.Backspace:
+		if len(a.name) > 0 {
+			a.name = a.name[:len(a.name)-1]
+		}
+	case k.Kind == terminal.Letter && k.Mod&(terminal.ModCtrl|terminal.ModAlt) == 0 && unicode.IsPrint(k.Char):
+		if len(a.name) < maxNameLen {
+			a.name = append(a.name, k.Char)
 		}
+	default:
+		return
 	}
+	a.drawNameEntry()
+}
+
+// showSummary shows the results of the finished game over the board.
+func (a *App) showSummary() {
+	a.nameEntry = false
+	a.gameOver = true
 
-	log("unsupported key: %+v", key)
-	return 0, false
+	a.term.BeginFrame()
+	defer a.term.EndFrame()
+
+	a.layout()
+	if !a.tooSmall {
+		a.redraw()
+	}
 }
 
-func (a *App) quit() {
-	a.ctxCancel()
+// pps is the pieces per second of the board.
+func (a *App) pps(b *board) float64 {
+	if s := a.elapsed.Seconds(); s > 0 {
+		return float64(b.gameplay.Pieces()) / s
+	}
+	return 0
 }
 
-func log(format string, a ...any) {
-	if len(a) == 0 {
-		fmt.Fprint(os.Stderr, format+"\n")
+func (a *App) summaryLines(b *board) []string {
+	var lines []string
+	if a.versus {
+		lines = a.versusLines(b)
 	} else {
-		fmt.Fprintf(os.Stderr, format+"\n", a...)
+		g := b.gameplay
+		lines = []string{
+			"",
+			" GAME OVER",
+			" " + string(a.overReason),
+			"",
+			fmt.Sprintf(" %-6s %d", "Score", g.Score()),
+			fmt.Sprintf(" %-6s %d", "Lines", g.Lines()),
+			fmt.Sprintf(" %-6s %d", "Level", g.Level()),
+			fmt.Sprintf(" %-6s %s", "Time", formatDuration(a.elapsed)),
+			fmt.Sprintf(" %-6s %.2f", "PPS", a.pps(b)),
+			fmt.Sprintf(" %-6s %d", "Faults", g.Faults()),
+		}
+	}
+
+	lines = append(lines, "")
+	if a.newGame != nil {
+		lines = append(lines, " enter restart", " esc menu")
 	}
+	if keys := a.keymap.Keys(keymap.Quit); len(keys) > 0 {
+		lines = append(lines, fmt.Sprintf(" %s quit", keys[0]))
+	}
+	return append(lines, "")
+}
+
+// onSummaryKey restarts the game with Enter, goes to the menu with Esc or quits with the quit key.
+// The letters stay free for the key bindings.
+func (a *App) onSummaryKey(k terminal.Key, action keymap.Action) {
+	switch {
+	case action == keymap.Quit:
+		a.quit()
+	case a.newGame == nil:
+		return
+	case k.Kind == terminal.Enter:
+		a.startGame(a.mode)
+	case k.Kind == terminal.Esc:
+		a.backToMenu()
+	}
+}
+
+// SetSandbox enables editing the board with the mouse.
+func (a *App) SetSandbox(enabled bool) {
+	a.sandbox = enabled
+}
+
+// onFrame applies the auto-repeat of held keys, counts the played time and shows the game to the watchers.
+func (a *App) onFrame() {
+	now := a.now()
+	if !a.lastFrame.IsZero() && !a.halted() {
+		a.elapsed += now.Sub(a.lastFrame)
+	}
+	a.lastFrame = now
+
+	cmds := make([][]game.Command, len(a.playing()))
+	for i, b := range a.playing() {
+		cmds[i] = b.repeater.Update()
+		a.syncGravity(b)
+	}
+	defer a.publish()
+	if a.halted() {
+		return
+	}
+	for i, b := range a.playing() {
+		if a.bot != nil && b == a.boards[0] {
+			a.botMove()
+			continue
+		}
+		if len(cmds[i]) > 0 && !b.over {
+			a.perform(b, cmds[i]...)
+		}
+	}
+}
+
+// botDelay paces the bot to be watchable.
+const botDelay = 80 * time.Millisecond
+
+// SetBot lets the bot play instead of the player, the keys still pause, open the options and quit.
+func (a *App) SetBot(b *bot.Bot) {
+	a.bot = b
+}
+
+// botMove makes the next move of the plan, the plan is made once per tetromino.
+func (a *App) botMove() {
+	now := a.now()
+	if now.Sub(a.botMoved) < botDelay {
+		return
+	}
+	b := a.boards[0]
+	if !a.botPlanned {
+		a.botPlan = a.bot.Plan(b.gameplay.Field(), b.gameplay.CurrentTetromino())
+		a.botPlanned = true
+	}
+	if len(a.botPlan) == 0 {
+		return
+	}
+
+	a.botMoved = now
+	cmd := a.botPlan[0]
+	a.botPlan = a.botPlan[1:]
+	a.press(b, cmd)
+	a.perform(b, cmd)
+}
+
+// press counts the key press of the command for the finesse, the replay and the opponent's copy count it too.
+func (a *App) press(b *board, cmd game.Command) {
+	if !b.gameplay.CountInput(cmd) {
+		return
+	}
+	a.record(func(r *replay.Recorder) error { return r.Press(cmd) })
+	a.send(b, func(c *netplay.Conn) error { return c.Press(cmd) })
+}
+
+// perform applies the commands to the current tetromino of the board and redraws it if it has moved.
+// Commands that can't move the tetromino, e.g. repeats against the wall, are neither logged nor recorded.
+func (a *App) perform(b *board, cmds ...game.Command) {
+	prev := b.gameplay.CurrentTetromino().Clone()
+	for _, cmd := range cmds {
+		if !b.gameplay.HandleCommand(cmd) {
+			continue
+		}
+		a.logger.Debug("command", "cmd", cmd.String())
+		a.record(func(r *replay.Recorder) error { return r.Command(cmd) })
+		a.send(b, func(c *netplay.Conn) error { return c.Command(cmd) })
+	}
+
+	curr := b.gameplay.CurrentTetromino()
+	if prev.Points == curr.Points {
+		return
+	}
+
+	a.term.BeginFrame()
+	defer a.term.EndFrame()
+
+	a.erasePiece(b, prev)
+	a.drawPiece(b)
+}
+
+// SetRecorder records every gravity tick and command of the game for a replay.
+func (a *App) SetRecorder(rec *replay.Recorder) {
+	a.recorder = rec
+}
+
+// SetReplay plays back the recorded steps instead of the gravity ticker and the player commands.
+func (a *App) SetReplay(steps <-chan replay.Step) {
+	a.replay = steps
+	a.replaying = true
+}
+
+// record writes a step of the recording, a failed recording is dropped without interrupting the game.
+func (a *App) record(write func(*replay.Recorder) error) {
+	if a.recorder == nil {
+		return
+	}
+	if err := write(a.recorder); err != nil {
+		a.logger.Error("record replay", "err", err)
+		a.recorder = nil
+	}
+}
+
+func (a *App) onReplayStep(s replay.Step, ok bool) {
+	if !ok {
+		a.logger.Info("replay finished")
+		a.replay = nil
+		a.drawStatus("Replay finished")
+		return
+	}
+
+	if s.Gravity {
+		a.onTick(a.boards[0])
+		return
+	}
+	if cmd, ok := game.ParseCommand(s.Press); ok {
+		a.press(a.boards[0], cmd)
+		return
+	}
+	if cmd, ok := game.ParseCommand(s.Command); ok {
+		a.perform(a.boards[0], cmd)
+	}
+}
+
+// syncGravity speeds up the gravity of the board while soft drop is held and brings it back on release.
+func (a *App) syncGravity(b *board) {
+	d := b.repeater.Gravity(b.gravity)
+	if d != b.currGravity {
+		b.ticker.Reset(d)
+		b.currGravity = d
+	}
+}
+
+func (a *App) quit() {
+	a.ctxCancel()
 }
 
 type Ticker interface {
@@ -233,10 +1199,6 @@ func (t *RealTicker) Reset(d time.Duration) {
 	t.ticker.Reset(d)
 }
 
-func exec_(cmd string, args ...string) error {
-	return exec.Command(cmd, args...).Run()
-}
-
 func fill[T any](xs []T, x T) {
 	for i := range len(xs) {
 		xs[i] = x
@@ -5,13 +5,24 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
 	"sync"
 	"testing"
 	"time"
 
+	"github.com/opennikish/tetris/internal/autorepeat"
+	"github.com/opennikish/tetris/internal/bot"
 	"github.com/opennikish/tetris/internal/game"
+	"github.com/opennikish/tetris/internal/keymap"
+	"github.com/opennikish/tetris/internal/netplay"
+	"github.com/opennikish/tetris/internal/replay"
+	"github.com/opennikish/tetris/internal/scores"
+	"github.com/opennikish/tetris/internal/settings"
+	"github.com/opennikish/tetris/internal/spectate"
 	"github.com/opennikish/tetris/internal/terminal"
 	"github.com/opennikish/tetris/internal/tui"
 )
@@ -23,12 +34,25 @@ func createTestApp(
 	stdout io.Writer,
 	ticker *TestTicker,
 ) *App {
-	term := terminal.NewTerminal(stdin, stdout, func(cmd string, args ...string) error { return nil })
+	return createTestAppWithClock(stdin, stdout, ticker, NewTestTicker(), &TestClock{})
+}
+
+func createTestAppWithClock(
+	stdin io.Reader,
+	stdout io.Writer,
+	ticker *TestTicker,
+	frames *TestTicker,
+	clock *TestClock,
+) *App {
+	term := terminal.NewTerminal(stdin, stdout, nopMode{})
 	return NewApp(
-		game.NewGameplay(),
+		game.NewGameplay(func(n int) int { return 0 }, game.Options{}),
 		term,
 		tui.NewPlayfieldRenderer(term, 0, 0),
 		ticker,
+		keymap.Default(),
+		frames,
+		autorepeat.New(autorepeat.DefaultConfig(), clock.Now),
 	)
 }
 
@@ -48,7 +72,7 @@ func TestHorizontalMoveDoesNotCrossWalls(t *testing.T) {
 	go func() {
 		err := app.Start(ctx)
 		if err != nil {
-			log("app.Start() returned err: %s", err)
+			slog.Error("app.Start() returned err", "err", err)
 		}
 	}()
 
@@ -160,7 +184,7 @@ func TestFallDownWithRotationPinTetro(t *testing.T) {
 	go func() {
 		err := app.Start(ctx)
 		if err != nil {
-			log("app.Start() returned err: %s", err)
+			slog.Error("app.Start() returned err", "err", err)
 		}
 	}()
 
@@ -331,7 +355,7 @@ func TestCementAfterFallDownToTheGroundOrAnotherTetromino(t *testing.T) {
 	go func() {
 		err := app.Start(ctx)
 		if err != nil {
-			log("app.Start() returned err: %s", err)
+			slog.Error("app.Start() returned err", "err", err)
 		}
 	}()
 
@@ -439,7 +463,7 @@ func TestActiveTetrominoDoesNotCrossCementedTetrominos(t *testing.T) {
 	go func() {
 		err := app.Start(ctx)
 		if err != nil {
-			log("app.Start() returned err: %s", err)
+			slog.Error("app.Start() returned err", "err", err)
 		}
 	}()
 
@@ -594,129 +618,1296 @@ func TestActiveTetrominoDoesNotCrossCementedTetrominos(t *testing.T) {
 	eq(t, expected, actual)
 }
 
-func eq[T comparable](t *testing.T, expected, actual T) {
-	if expected != actual {
-		t.Fatalf("expected: %v got: %v", expected, actual)
-	}
-}
+func TestHeldKeyShiftsAfterDAS(t *testing.T) {
+	stdout := NewScreenBuffer(25)
+	stdin, stdinWriter := io.Pipe()
+	defer stdinWriter.Close()
 
-type TestTicker struct {
-	C chan time.Time
+	ticker := NewTestTicker()
+	frames := NewTestTicker()
+	clock := &TestClock{}
+
+	app := createTestAppWithClock(stdin, stdout, ticker, frames, clock)
+
+	ctx := context.Background()
+	ctx, cancel := context.WithCancel(ctx)
+	t.Cleanup(cancel)
+
+	go func() {
+		err := app.Start(ctx)
+		if err != nil {
+			slog.Error("app.Start() returned err", "err", err)
+		}
+	}()
+
+	cmdController := NewCommandController(stdinWriter)
+
+	ticker.Tick(2)
+	cmdController.PressLeft(1)
+	time.Sleep(1 * time.Millisecond)
+	frames.Tick(1)
+	time.Sleep(1 * time.Millisecond)
+	expected := `                        
+<! . . . . . . . . . .!>
+<! . . .[] . . . . . .!>
+<! . .[][][] . . . . .!>
+<! . . . . . . . . . .!>
+`
+	actual := stdout.String()[:len(expected)]
+	eq(t, expected, actual)
+
+	// the terminal starts repeating the held key after its own delay, the shifts start from there
+	clock.Advance(200 * time.Millisecond)
+	cmdController.PressLeft(1)
+	time.Sleep(1 * time.Millisecond)
+	frames.Tick(1)
+	time.Sleep(1 * time.Millisecond)
+	expected = `                        
+<! . . . . . . . . . .!>
+<! . .[] . . . . . . .!>
+<! .[][][] . . . . . .!>
+<! . . . . . . . . . .!>
+`
+	actual = stdout.String()[:len(expected)]
+	eq(t, expected, actual)
+
+	clock.Advance(40 * time.Millisecond)
+	cmdController.PressLeft(1)
+	time.Sleep(1 * time.Millisecond)
+	frames.Tick(1)
+	time.Sleep(1 * time.Millisecond)
+	expected = `                        
+<! . . . . . . . . . .!>
+<! .[] . . . . . . . .!>
+<![][][] . . . . . . .!>
+<! . . . . . . . . . .!>
+`
+	actual = stdout.String()[:len(expected)]
+	eq(t, expected, actual)
 }
 
-func NewTestTicker() *TestTicker {
-	return &TestTicker{
-		C: make(chan time.Time),
+func TestLayoutCentresBoardAndPausesWhileWindowIsTooSmall(t *testing.T) {
+	stdin, stdinWriter := io.Pipe()
+	defer stdinWriter.Close()
+
+	ticker := NewTestTicker()
+	stdout := NewWindowBuffer(60, 0, 0)
+	app := createTestApp(stdin, stdout, ticker)
+	needLines, needCols := app.layoutSize()
+	stdout.lines, stdout.cols = needLines+4, needCols+6 // 2 lines above the board, 3 columns left of it
+
+	ctx := context.Background()
+	ctx, cancel := context.WithCancel(ctx)
+	t.Cleanup(cancel)
+
+	go func() {
+		err := app.Start(ctx)
+		if err != nil {
+			slog.Error("app.Start() returned err", "err", err)
+		}
+	}()
+
+	time.Sleep(1 * time.Millisecond)
+	eq(t, 3, strings.Index(stdout.Line(2+2), "<! . . ."))
+
+	stdout.Resize(needLines-1, needCols+6)
+	time.Sleep(1 * time.Millisecond)
+	ticker.Tick(3) // the game waits for a bigger window
+	time.Sleep(1 * time.Millisecond)
+	if !strings.HasPrefix(stdout.Line(1), fmt.Sprintf("Terminal too small: need %dx%d, got %dx%d", needCols, needLines, needCols+6, needLines-1)) {
+		t.Fatalf("expected the game paused, got:\n%s", stdout.String())
+	}
+	if !strings.HasPrefix(stdout.Line(2), "Game paused, enlarge the window or press q to quit") {
+		t.Fatalf("expected the bound quit key, got:\n%s", stdout.String())
+	}
+	if strings.Contains(stdout.String(), "<!") {
+		t.Fatalf("expected no board, got:\n%s", stdout.String())
 	}
-}
 
-func (t *TestTicker) Channel() <-chan time.Time {
-	return t.C
+	stdout.Resize(needLines, needCols)
+	time.Sleep(1 * time.Millisecond)
+	ticker.Tick(2)
+	time.Sleep(1 * time.Millisecond)
+	eq(t, "<! . . . . . . . . . .!>", stdout.Line(2)[:24])
+	eq(t, "<! . . . .[] . . . . .!>", stdout.Line(3)[:24])
+	eq(t, "<! . . .[][][] . . . .!>", stdout.Line(4)[:24])
 }
 
-func (t *TestTicker) Start() {}
+func TestReplayDrivesGameInsteadOfTickerAndKeys(t *testing.T) {
+	stdout := NewScreenBuffer(25)
+	stdin, stdinWriter := io.Pipe()
+	defer stdinWriter.Close()
 
-func (t *TestTicker) Stop() {}
+	ticker := NewTestTicker()
+	app := createTestApp(stdin, stdout, ticker)
+	steps := make(chan replay.Step)
+	app.SetReplay(steps)
 
-func (t *TestTicker) Tick(n int) {
-	for range n {
-		t.C <- time.Time{}
+	ctx := context.Background()
+	ctx, cancel := context.WithCancel(ctx)
+	t.Cleanup(cancel)
+
+	go func() {
+		err := app.Start(ctx)
+		if err != nil {
+			slog.Error("app.Start() returned err", "err", err)
+		}
+	}()
+
+	NewCommandController(stdinWriter).PressRight(2) // ignored while replaying
+	steps <- replay.Step{Gravity: true}
+	steps <- replay.Step{Gravity: true}
+	steps <- replay.Step{Command: "move-left"}
+	close(steps)
+	time.Sleep(1 * time.Millisecond)
+	expected := `                        
+<! . . . . . . . . . .!>
+<! . . .[] . . . . . .!>
+<! . .[][][] . . . . .!>
+<! . . . . . . . . . .!>
+`
+	actual := stdout.String()[:len(expected)]
+	eq(t, expected, actual)
+	if !strings.Contains(stdout.String(), "Replay finished") {
+		t.Fatalf("expected replay finished status, got:\n%s", stdout.String())
 	}
 }
 
-func (t *TestTicker) Reset(d time.Duration) {}
+func TestOptionsScreenTurnsGhostOnAndSaves(t *testing.T) {
+	stdout := NewScreenBuffer(25)
+	stdin, stdinWriter := io.Pipe()
+	defer stdinWriter.Close()
 
-type ScreenBuffer struct {
-	bytes     []byte
-	pos       int
-	maxColLen int
-	mu        sync.Mutex
-}
+	ticker := NewTestTicker()
+	app := createTestApp(stdin, stdout, ticker)
 
-func NewScreenBuffer(maxColLen int) *ScreenBuffer {
-	return &ScreenBuffer{maxColLen: maxColLen}
+	s := settings.Default()
+	s.ColorMode = tui.ColorNone
+	saved := make(chan settings.Settings, 1)
+	app.SetSettings(s, func(s settings.Settings) error {
+		saved <- s
+		return nil
+	})
+
+	ctx := context.Background()
+	ctx, cancel := context.WithCancel(ctx)
+	t.Cleanup(cancel)
+
+	go func() {
+		err := app.Start(ctx)
+		if err != nil {
+			slog.Error("app.Start() returned err", "err", err)
+		}
+	}()
+
+	cmdController := NewCommandController(stdinWriter)
+
+	stdinWriter.Write([]byte("o"))
+	cmdController.PressRotate(1) // up from the first row wraps to the last one
+	cmdController.PressRight(1)
+	time.Sleep(1 * time.Millisecond)
+	if !strings.Contains(stdout.String(), "> Ghost          < on >") {
+		t.Fatalf("expected ghost option turned on, got:\n%s", stdout.String())
+	}
+
+	stdinWriter.Write([]byte("\r"))
+	select {
+	case s := <-saved:
+		eq(t, true, s.Ghost)
+	case <-time.After(time.Second):
+		t.Fatal("settings weren't saved")
+	}
+
+	ticker.Tick(2)
+	time.Sleep(1 * time.Millisecond)
+	expected := `<! . . . . . . . . . .!>
+<! . . . .:: . . . . .!>
+<! . . .:::::: . . . .!>
+<!====================!>
+`
+	if !strings.Contains(stdout.String(), expected) {
+		t.Fatalf("expected ghost at the bottom, got:\n%s", stdout.String())
+	}
 }
 
-func (b *ScreenBuffer) Write(source []byte) (int, error) {
-	b.mu.Lock()
-	defer b.mu.Unlock()
+func TestMenuAndOptionsTakeClicks(t *testing.T) {
+	stdout := NewScreenBuffer(25)
+	stdin, stdinWriter := io.Pipe()
+	defer stdinWriter.Close()
+
+	ticker := NewTestTicker()
+	app := createTestApp(stdin, stdout, ticker)
+	saved := make(chan settings.Settings, 1)
+	app.SetSettings(settings.Default(), func(s settings.Settings) error {
+		saved <- s
+		return nil
+	})
+	started := make(chan string, 1)
+	app.SetMenu(func(mode string) []*game.Gameplay {
+		started <- mode
+		return []*game.Gameplay{game.NewGameplay(func(n int) int { return 0 }, game.Options{})}
+	})
 
-	if bytes.Equal(source, []byte("\033[H\033[2J")) {
-		log("test: clearscreen")
-		return len(source), nil
+	ctx := context.Background()
+	ctx, cancel := context.WithCancel(ctx)
+	t.Cleanup(cancel)
+
+	go func() {
+		err := app.Start(ctx)
+		if err != nil {
+			slog.Error("app.Start() returned err", "err", err)
+		}
+	}()
+
+	cmdController := NewCommandController(stdinWriter)
+
+	cmdController.Click(5, 3)  // options
+	cmdController.Click(5, 26) // ">" of the DAS
+	time.Sleep(1 * time.Millisecond)
+	if !strings.Contains(stdout.String(), "> DAS            < 177ms") {
+		t.Fatalf("expected DAS raised, got:\n%s", stdout.String())
 	}
 
-	if bytes.Contains(source, []byte("\033[")) {
-		row, col := b.extractPos(string(source))
+	stdinWriter.Write([]byte("\r"))
+	select {
+	case s := <-saved:
+		eq(t, 177*time.Millisecond, time.Duration(s.DAS))
+	case <-time.After(time.Second):
+		t.Fatal("settings weren't saved")
+	}
 
-		// row and col in escape sequence starts from 1, not from zero
-		row -= 1
-		col -= 1
+	cmdController.Click(4, 3) // play
+	cmdController.Click(9, 3) // below the modes
+	time.Sleep(1 * time.Millisecond)
+	eq(t, 0, len(started))
+
+	cmdController.Click(4, 3) // marathon
+	select {
+	case mode := <-started:
+		eq(t, modeMarathon, mode)
+	case <-time.After(time.Second):
+		t.Fatal("no game started")
+	}
+}
 
-		b.pos = row*b.maxColLen + col
+func TestHighScoreNameEntry(t *testing.T) {
+	stdout := NewScreenBuffer(25)
+	stdin, stdinWriter := io.Pipe()
+	defer stdinWriter.Close()
+
+	ticker := NewTestTicker()
+	frames := NewTestTicker()
+	clock := &TestClock{now: time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)}
+
+	term := terminal.NewTerminal(stdin, stdout, nopMode{})
+	app := NewApp(
+		game.NewGameplay(func(n int) int { return 1 }, game.Options{Width: 4, Height: 4, LineGoal: 1}),
+		term,
+		tui.NewPlayfieldRenderer(term, 0, 0),
+		ticker,
+		keymap.Default(),
+		frames,
+		autorepeat.New(autorepeat.DefaultConfig(), clock.Now),
+	)
+	app.now = clock.Now
+	store := scores.NewStore(filepath.Join(t.TempDir(), scores.FileName), scores.DefaultLimit)
+	app.SetScores(store, modeSprint, scores.ByTime, "alice")
+
+	done := make(chan error)
+	go func() {
+		done <- app.Start(context.Background())
+	}()
 
-		return len(source), nil
+	frames.Tick(1)
+	clock.Advance(3 * time.Second)
+	frames.Tick(1)
+	ticker.Tick(5) // the I tetromino falls down and clears the line
+	time.Sleep(1 * time.Millisecond)
+	if !strings.Contains(stdout.String(), "Name: alice_") {
+		t.Fatalf("expected name prompt, got:\n%s", stdout.String())
 	}
 
-	if len(source)+b.pos > len(b.bytes) {
-		b.bytes = append(b.bytes, make([]byte, len(source)+b.pos-len(b.bytes))...)
+	stdinWriter.Write([]byte("\x7f\x7f\x7f\x7f\x7fbob\r"))
+	time.Sleep(1 * time.Millisecond)
+	for _, line := range []string{" goal reached", " Lines  1", " Time   0:03.00", " PPS    0.33", " q quit"} {
+		if !strings.Contains(stdout.String(), line) {
+			t.Fatalf("expected %q in the summary, got:\n%s", line, stdout.String())
+		}
 	}
 
-	copy(b.bytes[b.pos:b.pos+len(source)], source)
-	b.pos += len(source)
+	stdinWriter.Write([]byte("q"))
+	select {
+	case err := <-done:
+		eq(t, nil, err)
+	case <-time.After(time.Second):
+		t.Fatal("app didn't stop")
+	}
 
-	return len(source), nil
+	tables, err := store.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	eq(t, 1, len(tables[modeSprint]))
+	e := tables[modeSprint][0]
+	eq(t, "bob", e.Name)
+	eq(t, 1, e.Lines)
+	eq(t, 3*time.Second, e.Duration)
+	eq(t, clock.Now(), e.Date.UTC())
 }
 
-func (b *ScreenBuffer) String() string {
-	b.mu.Lock()
-	defer b.mu.Unlock()
-	return string(b.bytes)
-}
+func TestMenuStartsGamesAndSummaryLeadsBack(t *testing.T) {
+	stdout := NewScreenBuffer(25)
+	stdin, stdinWriter := io.Pipe()
+	defer stdinWriter.Close()
 
-// extractPos extracts line and column from the escape sequence: "\033[{line};{col}H"
-func (b *ScreenBuffer) extractPos(s string) (int, int) {
-	sep := strings.Index(s, ";")
-	rawRow := s[2:sep]
-	rawCol := s[sep+1 : len(s)-1]
+	ticker := NewTestTicker()
+	app := createTestApp(stdin, stdout, ticker)
+	var started []string
+	app.SetMenu(func(mode string) []*game.Gameplay {
+		started = append(started, mode)
+		return []*game.Gameplay{game.NewGameplay(func(n int) int { return 0 }, game.Options{})}
+	})
 
-	row, err1 := strconv.Atoi(rawRow)
-	col, err2 := strconv.Atoi(rawCol)
-	if err1 != nil || err2 != nil {
-		panic(fmt.Sprintf("extractPos, given: %q" + s))
+	done := make(chan error)
+	go func() {
+		done <- app.Start(context.Background())
+	}()
+
+	ticker.Tick(1) // the menu holds the gravity
+	time.Sleep(1 * time.Millisecond)
+	if !strings.Contains(stdout.String(), "> Play") {
+		t.Fatalf("expected main menu, got:\n%s", stdout.String())
 	}
 
-	return row, col
-}
+	stdinWriter.Write([]byte("\r"))
+	stdinWriter.Write([]byte("\033[B")) // down to sprint
+	time.Sleep(1 * time.Millisecond)
+	if !strings.Contains(stdout.String(), "> Sprint, 40 lines") {
+		t.Fatalf("expected mode menu, got:\n%s", stdout.String())
+	}
 
-type CommandController struct {
-	stdinWriter io.Writer
-}
+	stdinWriter.Write([]byte("\r"))
+	time.Sleep(1 * time.Millisecond)
+	ticker.Tick(2)
+	time.Sleep(1 * time.Millisecond)
+	expected := `<! . . . . . . . . . .!>
+<! . . . .[] . . . . .!>
+<! . . .[][][] . . . .!>
+`
+	if !strings.Contains(stdout.String(), expected) {
+		t.Fatalf("expected a new game, got:\n%s", stdout.String())
+	}
 
-func NewCommandController(stdinWriter io.Writer) *CommandController {
-	return &CommandController{stdinWriter: stdinWriter}
-}
+	cmdController := NewCommandController(stdinWriter)
+	cmdController.PressQuite(1)
+	time.Sleep(1 * time.Millisecond)
+	if !strings.Contains(stdout.String(), " quit") {
+		t.Fatalf("expected game summary, got:\n%s", stdout.String())
+	}
 
-func (c *CommandController) PressLeft(n int) {
-	for range n {
-		c.stdinWriter.Write([]byte{27, 91, 68})
+	stdinWriter.Write([]byte("m")) // the letters are left to the key bindings
+	time.Sleep(1 * time.Millisecond)
+	if strings.Contains(stdout.String(), "> Play") {
+		t.Fatalf("expected the summary to stay, got:\n%s", stdout.String())
 	}
-}
 
-func (c *CommandController) PressRight(n int) {
-	for range n {
-		c.stdinWriter.Write([]byte{27, 91, 67})
+	stdinWriter.Write([]byte("\033"))
+	time.Sleep(100 * time.Millisecond) // a lone Esc waits for the rest of a sequence
+	if !strings.Contains(stdout.String(), "> Play") {
+		t.Fatalf("expected main menu after the game, got:\n%s", stdout.String())
 	}
-}
 
-func (c *CommandController) PressRotate(n int) {
-	for range n {
-		c.stdinWriter.Write([]byte("\033[A"))
+	stdinWriter.Write([]byte("\r\r")) // another marathon game
+	cmdController.PressQuite(1)
+	stdinWriter.Write([]byte("\r")) // and it again
+	cmdController.PressQuite(2)
+	select {
+	case err := <-done:
+		eq(t, nil, err)
+	case <-time.After(time.Second):
+		t.Fatal("app didn't stop")
 	}
+	eq(t, "sprint,marathon,marathon", strings.Join(started, ","))
 }
 
-func (c *CommandController) PressQuite(n int) {
-	for range n {
-		c.stdinWriter.Write([]byte("q"))
-	}
+func TestBotPlaysInsteadOfKeyboard(t *testing.T) {
+	stdout := NewScreenBuffer(25)
+	stdin, stdinWriter := io.Pipe()
+	defer stdinWriter.Close()
+
+	ticker := NewTestTicker()
+	frames := NewTestTicker()
+	clock := &TestClock{}
+	app := createTestAppWithClock(stdin, stdout, ticker, frames, clock)
+	app.now = clock.Now
+	app.SetBot(bot.New(bot.DefaultWeights()))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	go func() {
+		err := app.Start(ctx)
+		if err != nil {
+			slog.Error("app.Start() returned err", "err", err)
+		}
+	}()
+
+	NewCommandController(stdinWriter).PressRight(3) // ignored
+	ticker.Tick(1)
+	for range 10 {
+		clock.Advance(botDelay)
+		frames.Tick(1)
+	}
+	ticker.Tick(1) // locks the hard dropped tetromino
+	time.Sleep(1 * time.Millisecond)
+
+	expected := `<! . . . . . . . . . .!>
+<! . . . . . . . . . .!>
+<! . . . . . . . . . .!>
+<! .[] . . . . . . . .!>
+<![][][] . . . . . . .!>
+<!====================!>
+`
+	if !strings.Contains(stdout.String(), expected) {
+		t.Fatalf("expected the bot to put the tetromino flat in the corner, got:\n%s", stdout.String())
+	}
+}
+
+func TestFinesseFaultShowsInStatus(t *testing.T) {
+	stdout := NewScreenBuffer(25)
+	stdin, stdinWriter := io.Pipe()
+	defer stdinWriter.Close()
+
+	ticker := NewTestTicker()
+	app := createTestApp(stdin, stdout, ticker)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	go func() {
+		err := app.Start(ctx)
+		if err != nil {
+			slog.Error("app.Start() returned err", "err", err)
+		}
+	}()
+
+	cmdController := NewCommandController(stdinWriter)
+	ticker.Tick(2)
+	cmdController.PressLeft(1)
+	cmdController.PressRight(1) // back where it was, both moves are wasted
+	time.Sleep(1 * time.Millisecond)
+	ticker.Tick(20) // falls down and locks
+	time.Sleep(1 * time.Millisecond)
+
+	if !strings.Contains(stdout.String(), "Finesse faults: 1") {
+		t.Fatalf("expected a finesse fault in the status, got:\n%s", stdout.String())
+	}
+}
+
+func TestHeldShiftToWallIsOnePress(t *testing.T) {
+	stdout := NewScreenBuffer(25)
+	stdin, stdinWriter := io.Pipe()
+	defer stdinWriter.Close()
+
+	ticker := NewTestTicker()
+	frames := NewTestTicker()
+	clock := &TestClock{}
+	app := createTestAppWithClock(stdin, stdout, ticker, frames, clock)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	go func() {
+		err := app.Start(ctx)
+		if err != nil {
+			slog.Error("app.Start() returned err", "err", err)
+		}
+	}()
+
+	cmdController := NewCommandController(stdinWriter)
+	ticker.Tick(2)
+	cmdController.PressLeft(1)
+	time.Sleep(1 * time.Millisecond)
+	for _, d := range []time.Duration{200 * time.Millisecond, 100 * time.Millisecond} {
+		clock.Advance(d)
+		cmdController.PressLeft(1) // the terminal repeats the held key
+		time.Sleep(1 * time.Millisecond)
+		frames.Tick(1)
+	}
+	time.Sleep(1 * time.Millisecond)
+	expected := `<! . . . . . . . . . .!>
+<! .[] . . . . . . . .!>
+<![][][] . . . . . . .!>
+`
+	if !strings.Contains(stdout.String(), expected) {
+		t.Fatalf("expected the tetromino at the wall, got:\n%s", stdout.String())
+	}
+
+	ticker.Tick(20) // falls down and locks
+	time.Sleep(1 * time.Millisecond)
+	if strings.Contains(stdout.String(), "Finesse faults") {
+		t.Fatalf("expected no finesse fault, got:\n%s", stdout.String())
+	}
+}
+
+func TestVersusSplitsKeyboardAndSendsGarbage(t *testing.T) {
+	stdout := NewScreenBuffer(25)
+	stdin, stdinWriter := io.Pipe()
+	defer stdinWriter.Close()
+
+	ticker := NewTestTicker()
+	rivalTicker := NewTestTicker()
+	clock := &TestClock{}
+
+	term := terminal.NewTerminal(stdin, stdout, nopMode{})
+	app := NewApp(
+		game.NewGameplay(func(n int) int { return 1 }, game.Options{Width: 4, Height: 4}),
+		term,
+		tui.NewPlayfieldRenderer(term, 0, 0),
+		ticker,
+		keymap.Default(),
+		NewTestTicker(),
+		autorepeat.New(autorepeat.DefaultConfig(), clock.Now),
+	)
+	app.SetSecondPlayer(rivalTicker, autorepeat.New(autorepeat.DefaultConfig(), clock.Now))
+	rival := game.NewGameplay(func(n int) int { return 0 }, game.Options{})
+	app.SetVersus(rival)
+
+	done := make(chan error)
+	go func() {
+		done <- app.Start(context.Background())
+	}()
+
+	stdinWriter.Write([]byte("d"))                  // the left player, can't move on the narrow field
+	NewCommandController(stdinWriter).PressRight(1) // the right player
+	time.Sleep(1 * time.Millisecond)
+	rivalTicker.Tick(1)
+	ticker.Tick(6) // the I lands flat and clears the field
+	time.Sleep(1 * time.Millisecond)
+
+	stdinWriter.Write([]byte("q"))
+	select {
+	case err := <-done:
+		eq(t, nil, err)
+	case <-time.After(time.Second):
+		t.Fatal("app didn't stop")
+	}
+
+	expected := [4]game.Point{{X: 5, Y: 1}, {X: 4, Y: 2}, {X: 5, Y: 2}, {X: 6, Y: 2}}
+	eq(t, expected, rival.CurrentTetromino().Points)
+	eq(t, 10, rival.PendingGarbage()) // a perfect clear
+}
+
+func TestNetworkGameStreamsStepsAndTakesGarbage(t *testing.T) {
+	ln, err := netplay.Listen("127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	accepted := make(chan *netplay.Conn)
+	go func() {
+		conn, err := ln.Accept(context.Background(), netplay.Game{Seed: 1})
+		if err != nil {
+			slog.Error("accept", "err", err)
+		}
+		accepted <- conn
+	}()
+	opponent, err := netplay.Dial(context.Background(), ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer opponent.Close()
+	conn := <-accepted
+	defer conn.Close()
+
+	stdout := NewScreenBuffer(25)
+	stdin, stdinWriter := io.Pipe()
+	defer stdinWriter.Close()
+
+	ticker := NewTestTicker()
+	clock := &TestClock{}
+	app := createTestAppWithClock(stdin, stdout, ticker, NewTestTicker(), clock)
+	app.SetSecondPlayer(NewTestTicker(), autorepeat.New(autorepeat.DefaultConfig(), clock.Now))
+	rival := game.NewGameplay(func(n int) int { return 0 }, game.Options{})
+	app.SetRemote(conn, rival)
+
+	done := make(chan error)
+	go func() {
+		done <- app.Start(context.Background())
+	}()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	msgs := opponent.Receive(ctx)
+	nextStep := func() netplay.Message {
+		for {
+			select {
+			case m := <-msgs:
+				if m.Type == netplay.TypePong {
+					continue
+				}
+				m.At = 0
+				return m
+			case <-time.After(time.Second):
+				t.Fatal("no step from the app")
+			}
+		}
+	}
+
+	NewCommandController(stdinWriter).PressRight(1)
+	eq(t, netplay.Message{Type: netplay.TypeStep, Press: "move-right"}, nextStep()) // counted for the finesse
+	eq(t, netplay.Message{Type: netplay.TypeStep, Command: "move-right"}, nextStep())
+	ticker.Tick(1)
+	eq(t, netplay.Message{Type: netplay.TypeStep, Gravity: true}, nextStep())
+
+	opponent.Command(game.MoveLeft)
+	opponent.Attack(3)
+	eq(t, netplay.Message{Type: netplay.TypeStep, Lines: 3}, nextStep()) // the app queued the garbage
+	opponent.Close()
+	time.Sleep(1 * time.Millisecond)
+
+	stdinWriter.Write([]byte("q"))
+	select {
+	case err := <-done:
+		eq(t, nil, err)
+	case <-time.After(time.Second):
+		t.Fatal("app didn't stop")
+	}
+
+	expected := game.NewGameplay(func(n int) int { return 0 }, game.Options{})
+	expected.HandleCommand(game.MoveLeft)
+	eq(t, expected.CurrentTetromino().Points, rival.CurrentTetromino().Points)
+	eq(t, 3, app.boards[0].gameplay.PendingGarbage())
+	eq(t, reasonLeft, app.overReason)
+	eq(t, rival, app.loser.gameplay)
+}
+
+func TestNetworkTopOutsAtOnceAreDecidedByHost(t *testing.T) {
+	ln, err := netplay.Listen("127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	accepted := make(chan *netplay.Conn)
+	go func() {
+		conn, err := ln.Accept(context.Background(), netplay.Game{Seed: 1})
+		if err != nil {
+			slog.Error("accept", "err", err)
+		}
+		accepted <- conn
+	}()
+	joiner, err := netplay.Dial(context.Background(), ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer joiner.Close()
+	conn := <-accepted
+	defer conn.Close()
+
+	stdout := NewScreenBuffer(25)
+	stdin, stdinWriter := io.Pipe()
+	defer stdinWriter.Close()
+
+	opts := game.Options{Width: 4, Height: 4}
+	ticker := NewTestTicker()
+	term := terminal.NewTerminal(stdin, stdout, nopMode{})
+	app := NewApp(
+		game.NewGameplay(func(n int) int { return 0 }, opts),
+		term,
+		tui.NewPlayfieldRenderer(term, 0, 0),
+		ticker,
+		keymap.Default(),
+		NewTestTicker(),
+		autorepeat.New(autorepeat.DefaultConfig(), time.Now),
+	)
+	app.SetSecondPlayer(NewTestTicker(), autorepeat.New(autorepeat.DefaultConfig(), time.Now))
+	app.SetRemote(conn, game.NewGameplay(func(n int) int { return 0 }, opts))
+
+	done := make(chan error)
+	go func() {
+		done <- app.Start(context.Background())
+	}()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	msgs := joiner.Receive(ctx)
+	var hostOver netplay.Message
+	for hostOver.Type != netplay.TypeOver {
+		ticker.Tick(1)
+		for drained := false; !drained; {
+			select {
+			case m := <-msgs:
+				if m.Type == netplay.TypeOver {
+					hostOver = m
+				}
+			case <-time.After(time.Millisecond):
+				drained = true
+			}
+		}
+	}
+	eq(t, string(game.ReasonBlockOut), hostOver.Reason)
+
+	// The joiner topped out a moment before the host, its word was on the way.
+	joiner.Over(hostOver.At-time.Millisecond, game.ReasonTopOut)
+	var result netplay.Message
+	for result.Type != netplay.TypeResult {
+		select {
+		case result = <-msgs:
+		case <-time.After(time.Second):
+			t.Fatal("no result from the host")
+		}
+	}
+	eq(t, netplay.Joiner, result.Loser)
+	eq(t, string(game.ReasonTopOut), result.Reason)
+
+	stdinWriter.Write([]byte("q"))
+	select {
+	case err := <-done:
+		eq(t, nil, err)
+	case <-time.After(time.Second):
+		t.Fatal("app didn't stop")
+	}
+	eq(t, app.boards[1], app.loser)
+	eq(t, game.ReasonTopOut, app.overReason)
+	eq(t, true, app.boards[0].over)
+}
+
+func TestBroadcastShowsGameToWatcher(t *testing.T) {
+	b, err := spectate.Listen("127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Close()
+
+	stdout := NewScreenBuffer(25)
+	stdin, stdinWriter := io.Pipe()
+	defer stdinWriter.Close()
+	frames := NewTestTicker()
+	app := createTestAppWithClock(stdin, stdout, NewTestTicker(), frames, &TestClock{})
+	app.SetBroadcast(b)
+	done := make(chan error)
+	go func() {
+		done <- app.Start(context.Background())
+	}()
+	frames.Tick(1)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	conn, err := spectate.Dial(ctx, b.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	received := conn.Receive(ctx)
+
+	watchOut := NewScreenBuffer(25)
+	watchIn, watchInWriter := io.Pipe()
+	defer watchInWriter.Close()
+	watchTerm := terminal.NewTerminal(watchIn, watchOut, nopMode{})
+	watcher := NewWatcher(watchTerm, tui.NewPlayfieldRenderer(watchTerm, 0, 0), keymap.Default())
+	states := make(chan spectate.State)
+	watched := make(chan error)
+	go func() {
+		watched <- watcher.Start(context.Background(), states)
+	}()
+
+	next := func() spectate.State {
+		select {
+		case s := <-received:
+			states <- s
+			return s
+		case <-time.After(time.Second):
+			t.Fatal("no state from the app")
+		}
+		return spectate.State{}
+	}
+
+	expected := game.NewGameplay(func(n int) int { return 0 }, game.Options{})
+	eq(t, expected.CurrentTetromino().Points, next().Piece) // the snapshot
+	NewCommandController(stdinWriter).PressRight(1)
+	time.Sleep(1 * time.Millisecond)
+	frames.Tick(1)
+	s := next()
+	expected.HandleCommand(game.MoveRight)
+	eq(t, expected.CurrentTetromino().Points, s.Piece)
+	eq(t, 1, s.Level)
+	time.Sleep(1 * time.Millisecond)
+	if !strings.Contains(watchOut.String(), "Score 0  Lines 0") {
+		t.Fatalf("expected the stats on the watcher screen, got:\n%s", watchOut.String())
+	}
+
+	stdinWriter.Write([]byte("q"))
+	eq(t, nil, <-done)
+	b.Close()
+	close(states)
+	time.Sleep(1 * time.Millisecond)
+	if !strings.Contains(watchOut.String(), "The broadcast has") {
+		t.Fatalf("expected the end of the broadcast on the watcher screen, got:\n%s", watchOut.String())
+	}
+	watchInWriter.Write([]byte("q"))
+	eq(t, nil, <-watched)
+}
+
+func TestSandboxClickTogglesCells(t *testing.T) {
+	stdout := NewScreenBuffer(25)
+	stdin, stdinWriter := io.Pipe()
+	defer stdinWriter.Close()
+
+	ticker := NewTestTicker()
+
+	app := createTestApp(stdin, stdout, ticker)
+	app.SetSandbox(true)
+
+	ctx := context.Background()
+	ctx, cancel := context.WithCancel(ctx)
+	t.Cleanup(cancel)
+
+	go func() {
+		err := app.Start(ctx)
+		if err != nil {
+			slog.Error("app.Start() returned err", "err", err)
+		}
+	}()
+
+	cmdController := NewCommandController(stdinWriter)
+
+	cmdController.Click(21, 3)
+	cmdController.Click(21, 4) // the same cell, toggles it back
+	cmdController.Click(20, 22)
+	cmdController.Click(5, 2) // left border
+	ticker.Tick(2)
+	time.Sleep(1 * time.Millisecond)
+	cmdController.Click(3, 11) // under the T
+	time.Sleep(1 * time.Millisecond)
+	expected := `                        
+<! . . . . . . . . . .!>
+<! . . . .[] . . . . .!>
+<! . . .[][][] . . . .!>
+<! . . . . . . . . . .!>
+<! . . . . . . . . . .!>
+<! . . . . . . . . . .!>
+<! . . . . . . . . . .!>
+<! . . . . . . . . . .!>
+<! . . . . . . . . . .!>
+<! . . . . . . . . . .!>
+<! . . . . . . . . . .!>
+<! . . . . . . . . . .!>
+<! . . . . . . . . . .!>
+<! . . . . . . . . . .!>
+<! . . . . . . . . . .!>
+<! . . . . . . . . . .!>
+<! . . . . . . . . . .!>
+<! . . . . . . . . . .!>
+<! . . . . . . . . .[]!>
+<! . . . . . . . . . .!>
+<!====================!>
+<!\/\/\/\/\/\/\/\/\/\/!>
+`
+	actual := stdout.String()
+	eq(t, expected, actual)
+}
+
+func TestStartStopsInputReaderBeforeReturn(t *testing.T) {
+	stdout := NewScreenBuffer(25)
+	stdin, stdinWriter, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stdin.Close()
+	defer stdinWriter.Close()
+
+	app := createTestApp(stdin, stdout, NewTestTicker())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- app.Start(ctx)
+	}()
+
+	NewCommandController(stdinWriter).PressLeft(1)
+	time.Sleep(1 * time.Millisecond)
+	cancel()
+
+	select {
+	case err := <-done:
+		eq(t, nil, err)
+	case <-time.After(time.Second):
+		t.Fatal("app didn't stop")
+	}
+
+	stdinWriter.Write([]byte("q"))
+	buf := make([]byte, 1)
+	n, _ := stdin.Read(buf)
+	eq(t, "q", string(buf[:n]))
+}
+
+func TestCtrlCKeyQuits(t *testing.T) {
+	stdout := NewScreenBuffer(25)
+	stdin, stdinWriter := io.Pipe()
+	defer stdinWriter.Close()
+
+	app := createTestApp(stdin, stdout, NewTestTicker())
+
+	done := make(chan error)
+	go func() {
+		done <- app.Start(context.Background())
+	}()
+	time.Sleep(1 * time.Millisecond)
+
+	stdinWriter.Write([]byte("\033[99;5u")) // Ctrl-C of the kitty keyboard protocol, no SIGINT comes with it
+	select {
+	case err := <-done:
+		eq(t, nil, err)
+	case <-time.After(time.Second):
+		t.Fatal("app didn't stop")
+	}
+}
+
+func TestCtrlZKeySuspendsLocalTerminalOnly(t *testing.T) {
+	for _, c := range []struct {
+		stdout  io.Writer
+		suspend bool
+	}{
+		{NewScreenBuffer(25), true},
+		{NewWindowBuffer(60, 40, 80), false}, // a remote terminal, stopping the process would stop every session
+	} {
+		stdin, stdinWriter := io.Pipe()
+		mode := &recordingMode{}
+		term := terminal.NewTerminal(stdin, c.stdout, mode)
+		app := NewApp(
+			game.NewGameplay(func(n int) int { return 0 }, game.Options{}),
+			term,
+			tui.NewPlayfieldRenderer(term, 0, 0),
+			NewTestTicker(),
+			keymap.Default(),
+			NewTestTicker(),
+			autorepeat.New(autorepeat.DefaultConfig(), time.Now),
+		)
+		stopped := make(chan struct{}, 1)
+		app.stopProcess = func() error {
+			stopped <- struct{}{}
+			return nil
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan error)
+		go func() {
+			done <- app.Start(ctx)
+		}()
+		time.Sleep(1 * time.Millisecond)
+
+		stdinWriter.Write([]byte("\x1a")) // Ctrl-Z sent as is, like with the kitty keyboard protocol or over telnet
+		select {
+		case <-stopped:
+			eq(t, true, c.suspend)
+		case <-time.After(50 * time.Millisecond):
+			eq(t, false, c.suspend)
+		}
+
+		cancel()
+		eq(t, nil, <-done)
+		stdinWriter.Close()
+		if c.suspend {
+			eq(t, 2, mode.restored) // suspend, exit
+		} else {
+			eq(t, 1, mode.restored)
+		}
+	}
+}
+
+func TestStartRestoresTerminalAfterPanic(t *testing.T) {
+	stdout := NewScreenBuffer(25)
+	stdin, stdinWriter := io.Pipe()
+	defer stdinWriter.Close()
+
+	mode := &recordingMode{}
+	term := terminal.NewTerminal(stdin, stdout, mode)
+	app := NewApp(
+		game.NewGameplay(func(n int) int { return 0 }, game.Options{}),
+		term,
+		tui.NewPlayfieldRenderer(term, 0, 0),
+		panickingTicker{NewTestTicker()},
+		keymap.Default(),
+		NewTestTicker(),
+		autorepeat.New(autorepeat.DefaultConfig(), time.Now),
+	)
+
+	err := app.Start(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "ticker is broken") {
+		t.Fatalf("expected panic to be returned as error, got: %v", err)
+	}
+	// without a log the stack and the state are only in the error
+	for _, want := range []string{"panickingTicker.Start", "tick=0", "tetromino="} {
+		if !strings.Contains(err.Error(), want) {
+			t.Fatalf("expected %q in the error, got: %v", want, err)
+		}
+	}
+	eq(t, 1, mode.raw)
+	eq(t, 1, mode.restored)
+}
+
+type recordingMode struct {
+	raw, restored int
+}
+
+func (m *recordingMode) MakeRaw() error {
+	m.raw++
+	return nil
+}
+
+func (m *recordingMode) Restore() error {
+	m.restored++
+	return nil
+}
+
+type panickingTicker struct {
+	*TestTicker
+}
+
+func (panickingTicker) Start() {
+	panic("ticker is broken")
+}
+
+func eq[T comparable](t *testing.T, expected, actual T) {
+	if expected != actual {
+		t.Fatalf("expected: %v got: %v", expected, actual)
+	}
+}
+
+type nopMode struct{}
+
+func (nopMode) MakeRaw() error { return nil }
+
+func (nopMode) Restore() error { return nil }
+
+type TestTicker struct {
+	C chan time.Time
+}
+
+func NewTestTicker() *TestTicker {
+	return &TestTicker{
+		C: make(chan time.Time),
+	}
+}
+
+func (t *TestTicker) Channel() <-chan time.Time {
+	return t.C
+}
+
+func (t *TestTicker) Start() {}
+
+func (t *TestTicker) Stop() {}
+
+func (t *TestTicker) Tick(n int) {
+	for range n {
+		t.C <- time.Time{}
+	}
+}
+
+func (t *TestTicker) Reset(d time.Duration) {}
+
+type TestClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *TestClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *TestClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+type ScreenBuffer struct {
+	bytes     []byte
+	pos       int
+	maxColLen int
+	mu        sync.Mutex
+}
+
+func NewScreenBuffer(maxColLen int) *ScreenBuffer {
+	return &ScreenBuffer{maxColLen: maxColLen}
+}
+
+func (b *ScreenBuffer) Write(source []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	s := source
+	for len(s) > 0 {
+		if bytes.HasPrefix(s, []byte("\033[")) {
+			s = s[b.applyCSI(s):]
+			continue
+		}
+
+		n := bytes.IndexByte(s, '\033')
+		if n < 0 {
+			n = len(s)
+		}
+		b.put(s[:n])
+		s = s[n:]
+	}
+
+	return len(source), nil
+}
+
+// put writes the text at the cursor, a newline is kept and moves the cursor to the start of the next line.
+func (b *ScreenBuffer) put(text []byte) {
+	for len(text) > 0 {
+		n := bytes.IndexByte(text, '\n') + 1
+		if n == 0 {
+			n = len(text)
+		}
+		b.write(text[:n])
+		if text[n-1] == '\n' {
+			b.pos = (b.pos + b.maxColLen - 1) / b.maxColLen * b.maxColLen
+		}
+		text = text[n:]
+	}
+}
+
+func (b *ScreenBuffer) write(text []byte) {
+	if len(text)+b.pos > len(b.bytes) {
+		b.bytes = append(b.bytes, make([]byte, len(text)+b.pos-len(b.bytes))...)
+	}
+
+	copy(b.bytes[b.pos:b.pos+len(text)], text)
+	b.pos += len(text)
+}
+
+// applyCSI interprets a single control sequence "\033[{params}{final}" and returns its length.
+// Only cursor movement and clear screen affect the buffer, the rest (colours, private modes) is ignored.
+func (b *ScreenBuffer) applyCSI(s []byte) int {
+	end := 2
+	for end < len(s) && (s[end] < 0x40 || s[end] > 0x7e) {
+		end++
+	}
+	if end == len(s) {
+		panic(fmt.Sprintf("unterminated escape sequence: %q", s))
+	}
+
+	params := string(s[2:end])
+	switch s[end] {
+	case 'H':
+		if params == "" {
+			b.pos = 0
+			break
+		}
+		row, col := b.extractPos(params)
+
+		// row and col in escape sequence starts from 1, not from zero
+		row -= 1
+		col -= 1
+
+		b.pos = row*b.maxColLen + col
+	case 'C':
+		n, err := strconv.Atoi(params)
+		if err != nil {
+			panic(fmt.Sprintf("cursor right, given: %q", params))
+		}
+		b.pos += n
+	case 'J':
+		b.bytes = nil
+	}
+
+	return end + 1
+}
+
+func (b *ScreenBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return string(b.bytes)
+}
+
+// extractPos extracts line and column from the escape sequence params: "{line};{col}"
+func (b *ScreenBuffer) extractPos(s string) (int, int) {
+	rawRow, rawCol, _ := strings.Cut(s, ";")
+
+	row, err1 := strconv.Atoi(rawRow)
+	col, err2 := strconv.Atoi(rawCol)
+	if err1 != nil || err2 != nil {
+		panic(fmt.Sprintf("extractPos, given: %q", s))
+	}
+
+	return row, col
+}
+
+// Line returns the n-th line (1-based) of the screen, the cells never written are NUL.
+func (b *ScreenBuffer) Line(n int) string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	start := min((n-1)*b.maxColLen, len(b.bytes))
+	end := min(n*b.maxColLen, len(b.bytes))
+	return string(b.bytes[start:end])
+}
+
+// WindowBuffer is a ScreenBuffer reporting a window size, like the window of a remote terminal.
+type WindowBuffer struct {
+	*ScreenBuffer
+	mu          sync.Mutex
+	lines, cols int
+	resized     chan struct{}
+}
+
+func NewWindowBuffer(maxColLen, lines, cols int) *WindowBuffer {
+	w := &WindowBuffer{ScreenBuffer: NewScreenBuffer(maxColLen), resized: make(chan struct{})}
+	w.lines, w.cols = lines, min(cols, maxColLen)
+	return w
+}
+
+func (w *WindowBuffer) Size() (lines, cols int, err error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.lines, w.cols, nil
+}
+
+func (w *WindowBuffer) Resized() <-chan struct{} {
+	return w.resized
+}
+
+// Resize changes the window size, the columns stay within the buffer.
+func (w *WindowBuffer) Resize(lines, cols int) {
+	w.mu.Lock()
+	w.lines, w.cols = lines, min(cols, w.maxColLen)
+	w.mu.Unlock()
+	w.resized <- struct{}{}
+}
+
+type CommandController struct {
+	stdinWriter io.Writer
+}
+
+func NewCommandController(stdinWriter io.Writer) *CommandController {
+	return &CommandController{stdinWriter: stdinWriter}
+}
+
+func (c *CommandController) PressLeft(n int) {
+	for range n {
+		c.stdinWriter.Write([]byte{27, 91, 68})
+	}
+}
+
+func (c *CommandController) PressRight(n int) {
+	for range n {
+		c.stdinWriter.Write([]byte{27, 91, 67})
+	}
+}
+
+func (c *CommandController) PressRotate(n int) {
+	for range n {
+		c.stdinWriter.Write([]byte("\033[A"))
+	}
+}
+
+func (c *CommandController) PressQuite(n int) {
+	for range n {
+		c.stdinWriter.Write([]byte("q"))
+	}
+}
+
+func (c *CommandController) Click(line, col int) {
+	fmt.Fprintf(c.stdinWriter, "\033[<0;%d;%dM", col, line)
+	fmt.Fprintf(c.stdinWriter, "\033[<0;%d;%dm", col, line)
 }
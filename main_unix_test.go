@@ -0,0 +1,87 @@
+//go:build linux || darwin
+
+package main
+
+import (
+	"context"
+	"io"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/opennikish/tetris/internal/autorepeat"
+	"github.com/opennikish/tetris/internal/game"
+	"github.com/opennikish/tetris/internal/keymap"
+	"github.com/opennikish/tetris/internal/terminal"
+	"github.com/opennikish/tetris/internal/tui"
+)
+
+func TestSuspendRestoresTerminalAndResumesPaused(t *testing.T) {
+	stdout := NewScreenBuffer(25)
+	stdin, stdinWriter := io.Pipe()
+	defer stdinWriter.Close()
+
+	mode := &recordingMode{}
+	term := terminal.NewTerminal(stdin, stdout, mode)
+	ticker := NewTestTicker()
+	app := NewApp(
+		game.NewGameplay(func(n int) int { return 0 }, game.Options{}),
+		term,
+		tui.NewPlayfieldRenderer(term, 0, 0),
+		ticker,
+		keymap.Default(),
+		NewTestTicker(),
+		autorepeat.New(autorepeat.DefaultConfig(), time.Now),
+	)
+	stopped := make(chan struct{}, 1)
+	app.stopProcess = func() error {
+		stopped <- struct{}{}
+		return nil
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- app.Start(ctx)
+	}()
+	time.Sleep(1 * time.Millisecond)
+
+	// SIGCONT goes first, a stray SIGTSTP before the app catches it would stop the test itself
+	syscall.Kill(syscall.Getpid(), syscall.SIGCONT)
+	time.Sleep(5 * time.Millisecond)
+	if !strings.Contains(stdout.String(), "Paused, press any key") {
+		t.Fatalf("expected paused status, got:\n%s", stdout.String())
+	}
+
+	ticker.Tick(2) // the game doesn't advance while paused
+	time.Sleep(1 * time.Millisecond)
+	expected := `                        
+<! . . . . . . . . . .!>
+<! . . . . . . . . . .!>
+`
+	eq(t, expected, stdout.String()[:len(expected)])
+
+	NewCommandController(stdinWriter).PressRotate(1)
+	time.Sleep(1 * time.Millisecond)
+	if strings.Contains(stdout.String(), "Paused") {
+		t.Fatalf("expected status to be cleared, got:\n%s", stdout.String())
+	}
+
+	syscall.Kill(syscall.Getpid(), syscall.SIGTSTP)
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Fatal("app didn't stop the process")
+	}
+
+	cancel()
+	select {
+	case err := <-done:
+		eq(t, nil, err)
+	case <-time.After(time.Second):
+		t.Fatal("app didn't stop")
+	}
+	eq(t, 2, mode.raw)      // start, SIGCONT
+	eq(t, 2, mode.restored) // suspend, exit
+}
@@ -0,0 +1,249 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/opennikish/tetris/internal/game"
+	"github.com/opennikish/tetris/internal/keymap"
+	"github.com/opennikish/tetris/internal/scores"
+	"github.com/opennikish/tetris/internal/terminal"
+)
+
+// menuScreen is the menu shown in place of the board, menuNone while playing.
+type menuScreen int
+
+const (
+	menuNone menuScreen = iota
+	menuMain
+	menuModes
+	menuScores
+)
+
+// Items of the main menu.
+const (
+	itemPlay    = "Play"
+	itemOptions = "Options"
+	itemScores  = "High scores"
+	itemQuit    = "Quit"
+	itemBack    = "Back"
+)
+
+var mainItems = []string{itemPlay, itemOptions, itemScores, itemQuit}
+
+var modeLabels = map[string]string{
+	modeMarathon: "Marathon",
+	modeSprint:   fmt.Sprintf("Sprint, %d lines", sprintLines),
+	modeSandbox:  "Sandbox",
+	modeVersus:   "Versus, two players",
+}
+
+// menuScoresLen is how many entries of each table the high-scores screen shows.
+const menuScoresLen = 5
+
+// SetNewGame lets the player restart or go to the menu after the game over,
+// newGame creates the gameplays of the mode, one per player.
+func (a *App) SetNewGame(newGame func(mode string) []*game.Gameplay) {
+	a.newGame = newGame
+}
+
+// SetMenu is SetNewGame that shows the main menu on start.
+func (a *App) SetMenu(newGame func(mode string) []*game.Gameplay) {
+	a.SetNewGame(newGame)
+	a.menu = menuMain
+}
+
+func (a *App) openMenu(screen menuScreen) {
+	a.menu = screen
+	a.menuRow = 0
+	a.syncMouse()
+	a.drawMenu()
+}
+
+func (a *App) menuItems() []string {
+	switch a.menu {
+	case menuMain:
+		return mainItems
+	case menuModes:
+		var items []string
+		for _, mode := range a.menuModes() {
+			items = append(items, modeLabels[mode])
+		}
+		return append(items, itemBack)
+	}
+	return nil
+}
+
+// menuModes are the modes to pick from, versus needs the second player set up.
+func (a *App) menuModes() []string {
+	if len(a.boards) > 1 {
+		return modes
+	}
+	return slices.DeleteFunc(slices.Clone(modes), func(mode string) bool { return mode == modeVersus })
+}
+
+func (a *App) drawMenu() {
+	if a.tooSmall {
+		return
+	}
+
+	a.term.BeginFrame()
+	defer a.term.EndFrame()
+
+	switch a.menu {
+	case menuMain:
+		a.boards[0].renderer.DrawMenu("Tetris", a.menuItems(), a.menuRow, "up/down select, enter to pick")
+	case menuModes:
+		a.boards[0].renderer.DrawMenu("Mode", a.menuItems(), a.menuRow, "enter to start, esc to go back")
+	case menuScores:
+		a.boards[0].renderer.DrawPage("High scores", a.scoreLines(), "any key to go back")
+	}
+}
+
+// scoreLines lists the best entries of the modes with a high-score table.
+func (a *App) scoreLines() []string {
+	var tables scores.Tables
+	if a.scores != nil {
+		var err error
+		if tables, err = a.scores.Load(); err != nil {
+			a.logger.Error("load scores", "err", err)
+		}
+	}
+
+	var lines []string
+	for _, mode := range modes {
+		table := tables[mode]
+		if len(table) == 0 {
+			continue
+		}
+		if len(lines) > 0 {
+			lines = append(lines, "")
+		}
+		lines = append(lines, modeLabels[mode])
+		for i, e := range table[:min(len(table), menuScoresLen)] {
+			result := fmt.Sprint(e.Score)
+			if scoreOrder(mode) == scores.ByTime {
+				result = formatDuration(e.Duration)
+			}
+			lines = append(lines, fmt.Sprintf("%2d %-*s %s", i+1, maxNameLen, e.Name, result))
+		}
+	}
+
+	if len(lines) == 0 {
+		return []string{"No high scores yet."}
+	}
+	return lines
+}
+
+// onMenuKey moves through the menus with arrows or the rotate and soft drop keys of the keymap.
+func (a *App) onMenuKey(k terminal.Key, action keymap.Action) {
+	if a.menu == menuScores {
+		a.openMenu(menuMain)
+		return
+	}
+
+	n := len(a.menuItems())
+	switch {
+	case action == keymap.Quit && a.menu == menuMain:
+		a.quit()
+		return
+	case action == keymap.Quit || k.Kind == terminal.Esc:
+		a.openMenu(menuMain)
+		return
+	case k.Kind == terminal.Up || action == keymap.Rotate:
+		a.menuRow = (a.menuRow - 1 + n) % n
+	case k.Kind == terminal.Down || action == keymap.SoftDrop:
+		a.menuRow = (a.menuRow + 1) % n
+	case k.Kind == terminal.Enter || action == keymap.HardDrop:
+		a.pickMenuItem()
+		return
+	default:
+		return
+	}
+	a.drawMenu()
+}
+
+// onMenuClick picks the clicked item, a click anywhere leaves the high scores like a key.
+func (a *App) onMenuClick(m terminal.Mouse) {
+	if a.menu == menuScores {
+		a.openMenu(menuMain)
+		return
+	}
+	if i, ok := a.boards[0].renderer.RowAt(m.Line, len(a.menuItems())); ok {
+		a.menuRow = i
+		a.pickMenuItem()
+	}
+}
+
+func (a *App) pickMenuItem() {
+	item := a.menuItems()[a.menuRow]
+	switch {
+	case item == itemPlay:
+		a.openMenu(menuModes)
+	case item == itemOptions:
+		a.openOptions()
+	case item == itemScores:
+		a.openMenu(menuScores)
+	case item == itemQuit:
+		a.quit()
+	case item == itemBack:
+		a.openMenu(menuMain)
+	case a.menu == menuModes:
+		a.startGame(a.menuModes()[a.menuRow])
+	}
+}
+
+// startGame replaces the finished game with a new one of the mode, the settings stay as they are.
+func (a *App) startGame(mode string) {
+	a.logger.Info("start game", "mode", mode)
+	games := a.newGame(mode)
+	for i, g := range games {
+		g.SetLogger(a.logger)
+		a.boards[i].gameplay = g
+	}
+	a.setVersus(len(games) > 1)
+	a.mode = mode
+	a.order = scoreOrder(mode)
+	a.switchSandbox(mode == modeSandbox)
+
+	a.menu = menuNone
+	a.syncMouse()
+	a.paused = false
+	a.nameEntry = false
+	a.gameOver = false
+	a.tickCount = 0
+	a.elapsed = 0
+	a.lastFrame = time.Time{}
+	a.botPlanned = false
+	for _, b := range a.playing() {
+		b.repeater.Reset()
+		b.gravity = gravityFor(a.baseGravity, b.gameplay.Level())
+		a.syncGravity(b)
+		b.cacheField()
+	}
+
+	a.term.BeginFrame()
+	defer a.term.EndFrame()
+
+	a.term.Clear()
+	a.layout()
+	if !a.tooSmall {
+		a.redraw()
+	}
+}
+
+// backToMenu leaves the finished game for the main menu.
+func (a *App) backToMenu() {
+	a.gameOver = false
+	a.setVersus(false)
+	a.switchSandbox(false)
+	a.layout()
+	a.openMenu(menuMain)
+}
+
+// switchSandbox turns the editing of the board with the mouse on or off.
+func (a *App) switchSandbox(enabled bool) {
+	a.sandbox = enabled
+	a.syncMouse()
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/opennikish/tetris/internal/game"
+	"github.com/opennikish/tetris/internal/netplay"
+)
+
+// Reasons of the network game over.
+const (
+	reasonLeft         game.GameOverReason = "opponent left"
+	reasonDisconnected game.GameOverReason = "connection lost"
+	reasonDraw         game.GameOverReason = "draw"
+)
+
+const waitingResult = "Topped out, waiting for the opponent"
+
+// netOver keeps the top-outs of a network game until the host tells the winner. The times are
+// since the connection start of each side, the clocks start within half a round trip.
+type netOver struct {
+	own, rival     game.GameOverReason // empty while the game goes on
+	ownAt, rivalAt time.Duration
+}
+
+// SetRemote starts with a versus game against the opponent on the other end of the connection.
+// The own game is on the left board with the keys of the settings, the right board is a copy of
+// the opponent's game, it follows the steps coming from the connection, see SetSecondPlayer.
+func (a *App) SetRemote(conn *netplay.Conn, rival *game.Gameplay) {
+	a.remote = conn
+	a.netOver = netOver{}
+	a.SetVersus(rival)
+}
+
+// send streams a step of the own game to the opponent, the copies of the opponent's game stay quiet.
+// A broken connection ends the game once the receiving side notices it.
+func (a *App) send(b *board, write func(*netplay.Conn) error) {
+	if a.remote == nil || b != a.boards[0] {
+		return
+	}
+	if err := write(a.remote); err != nil {
+		a.logger.Error("send to opponent", "err", err)
+	}
+}
+
+// onRemote plays the opponent's steps on its copy and takes the garbage it sends. The copy keeps
+// following the opponent while the screen is halted, only the drawing waits.
+func (a *App) onRemote(m netplay.Message, ok bool) {
+	if !ok {
+		a.remoteMsgs = nil
+		if err := a.remote.Err(); err != nil && !a.gameOver {
+			a.logger.Error("opponent connection", "err", err)
+			a.onGameOver(reasonDisconnected)
+		}
+		return
+	}
+
+	own, rival := a.boards[0], a.boards[1]
+	switch m.Type {
+	case netplay.TypeStep:
+		switch {
+		case a.halted():
+			a.followQuietly(rival, m)
+		case m.Gravity:
+			a.onTick(rival)
+		case m.Command != "":
+			if cmd, ok := game.ParseCommand(m.Command); ok {
+				a.perform(rival, cmd)
+			}
+		case m.Press != "":
+			if cmd, ok := game.ParseCommand(m.Press); ok {
+				a.press(rival, cmd)
+			}
+		case m.Lines > 0:
+			rival.gameplay.ReceiveGarbage(m.Lines)
+			a.term.BeginFrame()
+			a.drawMeters()
+			a.term.EndFrame()
+		}
+	case netplay.TypeAttack:
+		own.gameplay.ReceiveGarbage(m.Lines)
+		a.send(own, func(c *netplay.Conn) error { return c.Garbage(m.Lines) })
+		a.logger.Debug("garbage", "lines", m.Lines)
+
+		if !a.halted() {
+			a.term.BeginFrame()
+			a.drawMeters()
+			a.term.EndFrame()
+		}
+	case netplay.TypeOver:
+		a.logger.Info("opponent topped out", "reason", m.Reason, "at", m.At)
+		a.netOver.rival, a.netOver.rivalAt = game.GameOverReason(m.Reason), m.At
+	case netplay.TypeResult:
+		if a.remote.IsHost() || a.gameOver {
+			return
+		}
+		switch m.Loser {
+		case netplay.Joiner:
+			a.loser = own
+		case netplay.Host:
+			a.loser = rival
+		}
+		a.onGameOver(game.GameOverReason(m.Reason))
+	case netplay.TypePong:
+		if !a.halted() {
+			a.term.BeginFrame()
+			msgs := []string{fmt.Sprintf("Ping %d ms", a.remote.Latency().Milliseconds())}
+			if own.over {
+				msgs = append(msgs, waitingResult)
+			}
+			a.drawStatus(msgs...)
+			a.term.EndFrame()
+		}
+	case netplay.TypeBye:
+		a.logger.Info("opponent left", "reason", m.Reason)
+		if !a.gameOver {
+			a.loser = rival
+			a.onGameOver(reasonLeft)
+		}
+	}
+	a.decide()
+}
+
+// followQuietly plays the opponent's step on its copy without drawing, see onTick.
+func (a *App) followQuietly(rival *board, m netplay.Message) {
+	g := rival.gameplay
+	switch {
+	case rival.over:
+	case m.Gravity:
+		for _, e := range g.Update() {
+			switch evt := e.(type) {
+			case game.AttackEvent:
+				rival.sent += evt.Lines
+			case game.GameOverEvent:
+				rival.over = true
+			}
+		}
+	case m.Command != "":
+		if cmd, ok := game.ParseCommand(m.Command); ok {
+			g.HandleCommand(cmd)
+		}
+	case m.Press != "":
+		if cmd, ok := game.ParseCommand(m.Press); ok {
+			g.CountInput(cmd)
+		}
+	case m.Lines > 0:
+		g.ReceiveGarbage(m.Lines)
+	}
+}
+
+// onTopOut stops the board of a network game that topped out. The own top-out goes to the opponent
+// with its time, the copy of the opponent's game waits for the opponent to say it.
+func (a *App) onTopOut(b *board, reason game.GameOverReason) {
+	b.over = true
+	if b != a.boards[0] {
+		return
+	}
+	a.netOver.own, a.netOver.ownAt = reason, a.remote.Elapsed()
+	a.logger.Info("topped out", "reason", reason, "at", a.netOver.ownAt)
+	a.send(b, func(c *netplay.Conn) error { return c.Over(a.netOver.ownAt, reason) })
+	a.drawStatus("", waitingResult)
+	a.decide()
+}
+
+// decide lets the host tell the winner once it knows which game topped out first: the one that
+// topped out earlier loses. A message the opponent sent after the own top-out proves its game was
+// still going on then.
+func (a *App) decide() {
+	if a.remote == nil || !a.remote.IsHost() || a.gameOver {
+		return
+	}
+
+	o := a.netOver
+	own, rival := a.boards[0], a.boards[1]
+	switch {
+	case o.own != "" && o.rival != "" && o.ownAt == o.rivalAt:
+		a.finish(nil, "", reasonDraw)
+	case o.rival != "" && (o.own == "" || o.rivalAt < o.ownAt):
+		a.finish(rival, netplay.Joiner, o.rival)
+	case o.own != "" && (o.rival != "" || a.remote.PeerAt() > o.ownAt):
+		a.finish(own, netplay.Host, o.own)
+	}
+}
+
+// finish ends the network game with the result of the host.
+func (a *App) finish(loser *board, side string, reason game.GameOverReason) {
+	a.logger.Info("result", "loser", side, "reason", reason)
+	a.send(a.boards[0], func(c *netplay.Conn) error { return c.Result(side, reason) })
+	a.loser = loser
+	a.onGameOver(reason)
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/opennikish/tetris/internal/game"
+	"github.com/opennikish/tetris/internal/scores"
+	"github.com/opennikish/tetris/internal/settings"
+	"github.com/opennikish/tetris/internal/telnet"
+	"github.com/opennikish/tetris/internal/terminal"
+)
+
+// negotiateTimeout is how long a new telnet client has to report its window size.
+const negotiateTimeout = time.Second
+
+func runServe(cfg serveConfig, stdout io.Writer) error {
+	logger, closeLog, err := openLog(cfg.log)
+	if err != nil {
+		return err
+	}
+	defer closeLog()
+
+	path, err := scoresPath(cfg.scores)
+	if err != nil {
+		return err
+	}
+	s := settings.Default()
+	if cfg.theme != "" {
+		s.Theme = cfg.theme
+	}
+
+	ln, err := net.Listen("tcp", cfg.telnet)
+	if err != nil {
+		return fmt.Errorf("listen: %w", err)
+	}
+	fmt.Fprintf(stdout, "Serving telnet on %s, Ctrl-C to stop\n", ln.Addr())
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	srv := &telnetServer{
+		limit:    cfg.sessions,
+		settings: s,
+		store:    scores.NewStore(path, scores.DefaultLimit),
+		logger:   logger,
+	}
+	return srv.serve(ctx, ln)
+}
+
+// telnetServer plays an independent game with every client, they share only the high scores.
+type telnetServer struct {
+	limit    int
+	settings settings.Settings
+	store    *scores.Store
+	logger   *slog.Logger
+}
+
+// serve runs a session for every client until the ctx is done, then waits for the sessions to end.
+// Clients over the limit are turned away.
+func (s *telnetServer) serve(ctx context.Context, ln net.Listener) error {
+	stop := context.AfterFunc(ctx, func() { ln.Close() })
+	defer stop()
+
+	slots := make(chan struct{}, s.limit)
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	for id := 1; ; id++ {
+		conn, err := ln.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return fmt.Errorf("accept: %w", err)
+		}
+
+		logger := s.logger.With("session", id, "remote", conn.RemoteAddr().String())
+		select {
+		case slots <- struct{}{}:
+		default:
+			logger.Warn("session limit reached", "limit", s.limit)
+			conn.Write([]byte("The server is full, try again later.\r\n"))
+			conn.Close()
+			continue
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-slots }()
+			s.session(ctx, conn, logger)
+		}()
+	}
+}
+
+// session plays the games of the client starting with the menu, the quit key or Ctrl-C ends it.
+func (s *telnetServer) session(ctx context.Context, conn net.Conn, logger *slog.Logger) {
+	defer conn.Close()
+	logger.Info("session started")
+
+	tc := telnet.NewConn(conn)
+	if err := tc.Negotiate(negotiateTimeout); err != nil {
+		logger.Warn("negotiate", "err", err)
+		return
+	}
+
+	logger.Info("client terminal", "type", tc.TermType())
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		select {
+		case <-tc.Interrupted():
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	var seed uint64
+	cfg := playConfig{mode: modeMarathon, level: 1, width: game.DefaultWidth, height: game.DefaultHeight}
+	newGame := gameFactory(cfg, logger, &seed)
+
+	app := newTermApp(terminal.NewTerminal(tc, tc, tc), menuGame(cfg), s.settings, nil)
+	app.SetLogger(logger)
+	app.SetEnv(clientEnv(tc.TermType()))
+	app.SetMenu(newGame)
+	app.SetScores(s.store, cfg.mode, scoreOrder(cfg.mode), "")
+
+	err := app.Start(ctx)
+	logger.Info("session ended", "err", err)
+}
+
+// clientEnv is the environment of the telnet client as far as the colors go: the terminal type it told,
+// the 16 colors every terminal has otherwise.
+func clientEnv(termType string) func(key string) string {
+	if termType == "" {
+		termType = "ansi"
+	}
+	return func(key string) string {
+		if key == "TERM" {
+			return termType
+		}
+		return ""
+	}
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/opennikish/tetris/internal/scores"
+	"github.com/opennikish/tetris/internal/settings"
+	"github.com/opennikish/tetris/internal/tui"
+)
+
+func TestTelnetServerTurnsAwayClientsOverLimit(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := &telnetServer{
+		limit:    1,
+		settings: settings.Default(),
+		store:    scores.NewStore(filepath.Join(t.TempDir(), "scores.json"), scores.DefaultLimit),
+		logger:   slog.New(slog.NewTextHandler(io.Discard, nil)),
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan error)
+	go func() {
+		done <- srv.serve(ctx, ln)
+	}()
+
+	first, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer first.Close()
+	first.Write([]byte("\xff\xfb\x1f\xff\xfa\x1f\x00\x50\x00\x18\xff\xf0\xff\xfc\x18")) // WILL NAWS, 80x24, WONT TTYPE
+
+	var screen strings.Builder
+	first.SetReadDeadline(time.Now().Add(time.Second))
+	buf := make([]byte, 4096)
+	for !strings.Contains(screen.String(), "High scores") {
+		n, err := first.Read(buf)
+		if err != nil {
+			t.Fatalf("no menu: %v, got %q", err, screen.String())
+		}
+		screen.Write(buf[:n])
+	}
+	eq(t, true, strings.Contains(screen.String(), "\x1b[2;29HTetris")) // centred in 80x24
+
+	second, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer second.Close()
+	second.SetReadDeadline(time.Now().Add(time.Second))
+	msg, _ := io.ReadAll(second)
+	eq(t, "The server is full, try again later.\r\n", string(msg))
+
+	cancel()
+	select {
+	case err := <-done:
+		eq(t, nil, err)
+	case <-time.After(time.Second):
+		t.Fatal("server didn't stop")
+	}
+}
+
+func TestClientEnvPicksColors(t *testing.T) {
+	eq(t, tui.Color256, tui.DetectColorMode(clientEnv("xterm-256color")))
+	eq(t, tui.Color16, tui.DetectColorMode(clientEnv("")))
+	eq(t, tui.ColorNone, tui.DetectColorMode(clientEnv("dumb")))
+}
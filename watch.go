@@ -0,0 +1,249 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/opennikish/tetris/internal/game"
+	"github.com/opennikish/tetris/internal/keymap"
+	"github.com/opennikish/tetris/internal/spectate"
+	"github.com/opennikish/tetris/internal/terminal"
+	"github.com/opennikish/tetris/internal/tui"
+)
+
+// statusLines are the lines under the watched board: the stats and the messages.
+const statusLines = 2
+
+// Watcher shows the game broadcast by another player, its keys only quit.
+type Watcher struct {
+	term     *terminal.Terminal
+	renderer *tui.PlayfieldRenderer
+	keymap   *keymap.Keymap
+	logger   *slog.Logger
+	state    *spectate.State // on the screen, nil until the first frame
+	field    *game.Playfield // the field of the state, the renderer takes the size from it
+	ended    bool            // the broadcast has ended
+	tooSmall bool
+	offsetX  int
+	offsetY  int
+}
+
+func NewWatcher(term *terminal.Terminal, renderer *tui.PlayfieldRenderer, keymap *keymap.Keymap) *Watcher {
+	return &Watcher{
+		term:     term,
+		renderer: renderer,
+		keymap:   keymap,
+		logger:   slog.New(slog.NewTextHandler(io.Discard, nil)),
+	}
+}
+
+// SetLogger sends the log of the watcher and its terminal to the logger, it's discarded by default.
+func (w *Watcher) SetLogger(l *slog.Logger) {
+	w.logger = l
+	w.term.SetLogger(l)
+}
+
+// Start shows the states until the quit key, the screen stays after the broadcast has ended.
+func (w *Watcher) Start(ctx context.Context, states <-chan spectate.State) error {
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if err := w.term.UseRawModeNoEcho(); err != nil {
+		return fmt.Errorf("configure terminal: %w", err)
+	}
+	w.term.EnterAltScreen()
+	w.term.HideCursor()
+	defer func() {
+		w.term.FlushFrames()
+		w.term.ShowCursor()
+		w.term.ExitAltScreen()
+		if err := w.term.RestoreMode(); err != nil {
+			w.logger.Error("restore terminal", "err", err)
+		}
+	}()
+
+	inputCtx, stopInput := context.WithCancel(ctx)
+	events, errc := w.term.WatchInput(inputCtx)
+	defer func() {
+		stopInput()
+		for range errc {
+		}
+	}()
+	resized := w.term.WatchResize(ctx)
+
+	w.term.BeginFrame()
+	w.layout()
+	w.redraw()
+	w.term.EndFrame()
+
+	for {
+		select {
+		case e := <-events:
+			if k, ok := e.(terminal.Key); ok && k.Action == terminal.Press {
+				if action, _ := w.keymap.Action(k); action == keymap.Quit {
+					return nil
+				}
+			}
+		case s, ok := <-states:
+			if !ok {
+				states = nil
+				w.ended = true
+			}
+			w.show(s, ok)
+		case <-resized:
+			w.term.BeginFrame()
+			w.layout()
+			w.redraw()
+			w.term.EndFrame()
+		case <-ctx.Done():
+			return nil
+		case err, ok := <-errc:
+			if !ok {
+				return nil
+			}
+			return fmt.Errorf("read ui commands: %w", err)
+		}
+	}
+}
+
+// show draws the changes of the state, a new game or the game over take a full redraw.
+func (w *Watcher) show(s spectate.State, ok bool) {
+	w.term.BeginFrame()
+	defer w.term.EndFrame()
+
+	prev := w.state
+	if !ok {
+		w.drawStatus()
+		return
+	}
+	w.state = &s
+	if prev == nil || !sameSize(*prev, s) {
+		w.field = game.NewPlayfield(len(s.Field[0]), len(s.Field))
+		w.layout()
+		w.redraw()
+		return
+	}
+	switch {
+	case w.tooSmall:
+		return
+	case prev.Over != s.Over || len(prev.Next) != len(s.Next):
+		w.redraw()
+		return
+	case s.Over:
+		w.drawStatus()
+		return
+	}
+
+	w.erasePiece(prev.Piece)
+	for i, line := range s.Field {
+		for j, ck := range line {
+			if ck != prev.Field[i][j] {
+				w.renderer.RedrawCell(i, j, ck)
+			}
+		}
+	}
+	w.renderer.DrawTetro(&game.Tetromino{Points: s.Piece}, game.CellBlock)
+	w.drawPreview()
+	w.drawStatus()
+}
+
+func sameSize(a, b spectate.State) bool {
+	return len(a.Field) == len(b.Field) && len(a.Field[0]) == len(b.Field[0])
+}
+
+// erasePiece draws the field cells where the falling tetromino was.
+func (w *Watcher) erasePiece(piece [4]game.Point) {
+	for _, p := range piece {
+		if p.Y >= 1 {
+			w.renderer.RedrawCell(p.Y-1, p.X, w.state.Field[p.Y-1][p.X]) // the points count the hidden line
+		}
+	}
+}
+
+// layout centres the board in the terminal window like the game does.
+func (w *Watcher) layout() {
+	if w.field == nil {
+		return
+	}
+	lines, cols, err := w.term.Size()
+	if err != nil {
+		w.logger.Warn("window size", "err", err)
+		return
+	}
+
+	needLines, needCols := tui.LayoutSize(w.field, len(w.state.Next))
+	needLines += statusLines
+	w.tooSmall = lines < needLines || cols < needCols
+	w.offsetX, w.offsetY = max(0, (cols-needCols)/2), max(0, (lines-needLines)/2)
+	w.renderer.SetOffset(w.offsetX, w.offsetY)
+}
+
+func (w *Watcher) redraw() {
+	w.term.Clear()
+	if w.state == nil {
+		w.term.SetCursor(1, 1)
+		w.term.Print("Waiting for the game, press q to quit")
+		if w.ended {
+			w.term.SetCursor(2, 1)
+			w.term.Print("The broadcast has ended")
+		}
+		return
+	}
+	if w.tooSmall {
+		w.term.SetCursor(1, 1)
+		w.term.Print("Terminal too small, enlarge the window or press q to quit")
+		return
+	}
+
+	for i, line := range w.state.Field {
+		for j, ck := range line {
+			if w.field.Cell(i, j) != ck {
+				w.field.ToggleCell(i, j)
+			}
+		}
+	}
+	w.renderer.Draw(w.field)
+	w.drawPreview()
+	w.drawStatus()
+	if w.state.Over {
+		w.renderer.DrawOverlay(w.field, []string{"", " GAME OVER", " " + w.state.Reason, ""})
+		return
+	}
+	w.renderer.DrawTetro(&game.Tetromino{Points: w.state.Piece}, game.CellBlock)
+}
+
+func (w *Watcher) drawPreview() {
+	next := make([]*game.Tetromino, len(w.state.Next))
+	for i, points := range w.state.Next {
+		next[i] = &game.Tetromino{Points: points}
+	}
+	if len(next) > 0 {
+		w.renderer.DrawPreview(w.field, next)
+	}
+}
+
+// drawStatus prints the stats of the game under the board and whether the broadcast has ended.
+func (w *Watcher) drawStatus() {
+	if w.state == nil || w.tooSmall {
+		w.redraw()
+		return
+	}
+	s := w.state
+	lines, cols := tui.LayoutSize(w.field, len(s.Next))
+	msgs := []string{
+		fmt.Sprintf("%s  Score %d  Lines %d  Level %d  %s", s.Mode, s.Score, s.Lines, s.Level, formatDuration(s.Time)),
+		"Watching, press q to quit",
+	}
+	if w.ended {
+		msgs[1] = "The broadcast has ended, press q to quit"
+	}
+	for i, msg := range msgs {
+		w.term.SetCursor(w.offsetY+lines+1+i, w.offsetX+1)
+		w.term.Printf("%-*s", cols, msg)
+	}
+}